@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jposo/stateflow/stateflow"
+)
+
+const benchUsage = "Usage: stateflow bench [-inputs <file>] [-time <duration>] <filename>[:automaton]"
+
+// runBench compares the throughput of the interpreter and of the compiled
+// program on every automaton of a file, or the one named by
+// `file.sf:name`. Inputs are read one per line.
+func runBench(args []string) int {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	inputsFile := flags.String("inputs", "", "file with one input per line (defaults to inputs covering every transition)")
+	duration := flags.Duration("time", time.Second, "how long to run each automaton in each mode")
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, benchUsage)
+		return 1
+	}
+
+	defs, status := loadTarget(positional[0])
+	if defs == nil {
+		return status
+	}
+	var inputs []string
+	if *inputsFile != "" {
+		contents, err := os.ReadFile(*inputsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+			return 1
+		}
+		// An empty file has no inputs, rather than a single empty one
+		inputs = []string{}
+		if len(contents) > 0 {
+			for _, line := range strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n") {
+				inputs = append(inputs, strings.TrimSuffix(line, "\r"))
+			}
+		}
+	}
+
+	results, err := stateflow.Bench(defs, inputs, *duration)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error running benchmark: %v\n", err)
+		return 1
+	}
+	for _, result := range results {
+		fmt.Printf("%s (%s, %d inputs)\n", result.Automaton, result.Engine, result.Inputs)
+		fmt.Printf("  interpreted %14.0f runs/s %10.1f MB/s\n",
+			result.Interpreted.RunsPerSecond(), result.Interpreted.BytesPerSecond()/1e6)
+		fmt.Printf("  compiled    %14.0f runs/s %10.1f MB/s  %.1fx\n",
+			result.Compiled.RunsPerSecond(), result.Compiled.BytesPerSecond()/1e6, result.Speedup())
+	}
+	return 0
+}
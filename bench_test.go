@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBenchEmptyInputsFile(t *testing.T) {
+	dir := t.TempDir()
+	spec := filepath.Join(dir, "door.sf")
+	inputs := filepath.Join(dir, "inputs.txt")
+	source := "dfa door {\n  initial closed;\n  final open;\n\n  on closed -> open when \"push\";\n  on open -> open   when \"wait\";\n}\n"
+	if err := os.WriteFile(spec, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(inputs, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := runBench([]string{"-inputs", inputs, "-time", "1ms", spec}); status != 1 {
+		t.Errorf("Expected status 1 for a file without inputs, got %d", status)
+	}
+}
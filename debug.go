@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jposo/stateflow/stateflow"
+)
+
+// stringList is a flag that can be repeated
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// runDebug starts an interactive debugger over a program. Each --input is
+// an argument of `main`, or an input of the automaton named by
+// `file.sf:name`.
+func runDebug(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ExitOnError)
+	var inputs stringList
+	flags.Var(&inputs, "input", "input to debug; repeat for several")
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: stateflow debug <filename>[:automaton] --input <input>...")
+		return 1
+	}
+
+	filename, name := splitTarget(positional[0])
+	defs, status := loadProgram(filename)
+	if defs == nil {
+		return status
+	}
+	interpreter := stateflow.NewInterpreter()
+	if err := interpreter.Define(defs); err != nil {
+		fmt.Fprint(os.Stderr, err.Error())
+		return 70 // Runtime Error
+	}
+
+	var invocations []stateflow.Invocation
+	if name == "" {
+		var err error
+		invocations, err = interpreter.Invocations("main", inputs)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, strings.TrimRight(err.Error(), "\n"))
+			return 70 // Runtime Error
+		}
+	} else {
+		automaton := interpreter.Automaton(name)
+		if automaton == nil {
+			fmt.Fprintf(os.Stderr, "Unknown automaton '%s'.\n", name)
+			return 1
+		}
+		for _, input := range inputs {
+			invocations = append(invocations, stateflow.Invocation{Automaton: automaton, Input: input})
+		}
+	}
+
+	debugger := stateflow.NewDebugger(os.Stdout, invocations)
+	if err := debugger.Run(os.Stdin); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jposo/stateflow/stateflow"
+)
+
+// runExport writes the automata of a file, or the one named by
+// `file.sf:name`, in another format
+func runExport(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "dot", "output format: dot, mermaid, plantuml, scxml, jflap, tikz, graphml or json")
+	output := flags.String("o", "", "write to this file instead of stdout")
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: stateflow export --format <format> [-o <output>] <filename>[:automaton]")
+		return 1
+	}
+
+	defs, status := loadTarget(positional[0])
+	if defs == nil {
+		return status
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating file: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if err := stateflow.Export(w, *format, defs); err != nil {
+		fmt.Fprintf(os.Stderr, "Error exporting: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jposo/stateflow/stateflow"
+)
+
+// runFmt rewrites the given files into the canonical layout. With --check
+// or --diff the files are left untouched and the exit status reports
+// whether any of them is not formatted.
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	check := flags.Bool("check", false, "list files that are not formatted and exit with status 1")
+	diff := flags.Bool("diff", false, "print the changes formatting would make instead of writing them")
+	filenames := parseInterspersed(flags, args)
+	if len(filenames) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: stateflow fmt [--check] [--diff] <filename>...")
+		return 1
+	}
+
+	status := 0
+	for _, filename := range filenames {
+		source, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+			return 1
+		}
+
+		formatted, err := stateflow.Format(source)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s", filename, err.Error())
+			status = 65
+			continue
+		}
+		if bytes.Equal(source, formatted) {
+			continue
+		}
+
+		if *check {
+			fmt.Println(filename)
+		}
+		if *diff {
+			fmt.Print(unifiedDiff(filename, string(source), string(formatted)))
+		}
+		if *check || *diff {
+			status = max(status, 1)
+			continue
+		}
+
+		if err := os.WriteFile(filename, formatted, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing file: %v\n", err)
+			return 1
+		}
+	}
+	return status
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFmtCheckAfterFilename(t *testing.T) {
+	source := "dfa door {\ninitial closed;\nfinal open;\non closed -> open when \"push\";\non open -> open when \"wait\";\n}\n"
+	filename := filepath.Join(t.TempDir(), "door.sf")
+	if err := os.WriteFile(filename, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := runFmt([]string{filename, "--check"}); status != 1 {
+		t.Errorf("Expected status 1 for an unformatted file, got %d", status)
+	}
+	after, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != source {
+		t.Errorf("Expected --check to leave the file untouched, got:\n%s", after)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jposo/stateflow/stateflow"
+)
+
+const genUsage = `Usage:
+  stateflow gen go [-pkg <package>] [-workflow] [-o <output.go>] [-tests=false] <filename>[:automaton]
+  stateflow gen c [-o <output.c>] [-tests=false] <filename>[:automaton]
+  stateflow gen ts [-o <output.ts>] <filename>[:automaton]
+  stateflow gen python [-o <output.py>] [-tests=false] <filename>[:automaton]
+  stateflow gen sql [-dialect postgres|sqlite] [-table <table>] [-column <column>] [-o <output.sql>] <filename>[:automaton]`
+
+// runGen generates code in another language from the automata of a file,
+// or the one named by `file.sf:name`
+func runGen(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, genUsage)
+		return 1
+	}
+	switch args[0] {
+	case "go":
+		return runGenGo(args[1:])
+	case "c":
+		return runGenC(args[1:])
+	case "ts":
+		return runGenTS(args[1:])
+	case "python":
+		return runGenPython(args[1:])
+	case "sql":
+		return runGenSQL(args[1:])
+	}
+	fmt.Fprintf(os.Stderr, "Unknown language '%s'.\n%s\n", args[0], genUsage)
+	return 1
+}
+
+// runGenGo writes a Go matcher, or a typed state machine with -workflow,
+// and its tests. The package defaults to the one go generate is running in.
+func runGenGo(args []string) int {
+	flags := flag.NewFlagSet("gen go", flag.ExitOnError)
+	pkg := flags.String("pkg", os.Getenv("GOPACKAGE"), "package of the generated code (defaults to $GOPACKAGE)")
+	workflow := flags.Bool("workflow", false, "generate typed state machines with events and hooks instead of matchers")
+	output := flags.String("o", "", "write to this file instead of <file>_stateflow.go")
+	tests := flags.Bool("tests", true, "also write tests next to the output")
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, genUsage)
+		return 1
+	}
+	if *pkg == "" {
+		fmt.Fprintln(os.Stderr, "No package name: use -pkg or run from go generate.")
+		return 1
+	}
+
+	defs, status := loadTarget(positional[0])
+	if defs == nil {
+		return status
+	}
+	if *output == "" {
+		filename, _ := splitTarget(positional[0])
+		*output = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)) + "_stateflow.go"
+	}
+
+	generate, generateTests := stateflow.GenerateGo, stateflow.GenerateGoTests
+	if *workflow {
+		generate, generateTests = stateflow.GenerateGoWorkflow, stateflow.GenerateGoWorkflowTests
+	}
+	var code, testCode bytes.Buffer
+	err := generate(&code, *pkg, defs)
+	if err == nil && *tests {
+		err = generateTests(&testCode, *pkg, defs)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating code: %v\n", err)
+		return 1
+	}
+
+	files := map[string][]byte{*output: code.Bytes()}
+	if *tests {
+		files[strings.TrimSuffix(*output, ".go")+"_test.go"] = testCode.Bytes()
+	}
+	return writeFiles(files)
+}
+
+// runGenC writes a C header and source, next to each other, and a test
+// program replaying samples
+func runGenC(args []string) int {
+	flags := flag.NewFlagSet("gen c", flag.ExitOnError)
+	output := flags.String("o", "", "write to this file instead of <file>_stateflow.c; the header gets the .h extension")
+	tests := flags.Bool("tests", true, "also write a test program next to the output")
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, genUsage)
+		return 1
+	}
+
+	defs, status := loadTarget(positional[0])
+	if defs == nil {
+		return status
+	}
+	base := strings.TrimSuffix(*output, ".c")
+	if *output == "" {
+		filename, _ := splitTarget(positional[0])
+		base = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)) + "_stateflow"
+	}
+	headerName := filepath.Base(base) + ".h"
+
+	var header, source, testSource bytes.Buffer
+	err := stateflow.GenerateC(&header, &source, headerName, defs)
+	if err == nil && *tests {
+		err = stateflow.GenerateCTests(&testSource, headerName, defs)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating code: %v\n", err)
+		return 1
+	}
+
+	files := map[string][]byte{base + ".h": header.Bytes(), base + ".c": source.Bytes()}
+	if *tests {
+		files[base+"_test.c"] = testSource.Bytes()
+	}
+	return writeFiles(files)
+}
+
+// runGenTS writes an ES module with the types and matchers of every dfa
+func runGenTS(args []string) int {
+	flags := flag.NewFlagSet("gen ts", flag.ExitOnError)
+	output := flags.String("o", "", "write to this file instead of <file>_stateflow.ts")
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, genUsage)
+		return 1
+	}
+
+	defs, status := loadTarget(positional[0])
+	if defs == nil {
+		return status
+	}
+	if *output == "" {
+		filename, _ := splitTarget(positional[0])
+		*output = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)) + "_stateflow.ts"
+	}
+
+	var code bytes.Buffer
+	if err := stateflow.GenerateTypeScript(&code, defs); err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating code: %v\n", err)
+		return 1
+	}
+	return writeFiles(map[string][]byte{*output: code.Bytes()})
+}
+
+// runGenPython writes a Python module and pytest cases next to it, in
+// test_<module>.py
+func runGenPython(args []string) int {
+	flags := flag.NewFlagSet("gen python", flag.ExitOnError)
+	output := flags.String("o", "", "write to this file instead of <file>_stateflow.py")
+	tests := flags.Bool("tests", true, "also write pytest cases next to the output")
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, genUsage)
+		return 1
+	}
+
+	defs, status := loadTarget(positional[0])
+	if defs == nil {
+		return status
+	}
+	if *output == "" {
+		filename, _ := splitTarget(positional[0])
+		*output = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)) + "_stateflow.py"
+	}
+	module := strings.TrimSuffix(filepath.Base(*output), ".py")
+
+	var code, testCode bytes.Buffer
+	err := stateflow.GeneratePython(&code, defs)
+	if err == nil && *tests {
+		err = stateflow.GeneratePythonTests(&testCode, module, defs)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating code: %v\n", err)
+		return 1
+	}
+
+	files := map[string][]byte{*output: code.Bytes()}
+	if *tests {
+		files[filepath.Join(filepath.Dir(*output), "test_"+module+".py")] = testCode.Bytes()
+	}
+	return writeFiles(files)
+}
+
+// runGenSQL writes a schema enforcing the lifecycle of an automaton on a
+// status column
+func runGenSQL(args []string) int {
+	flags := flag.NewFlagSet("gen sql", flag.ExitOnError)
+	dialect := flags.String("dialect", "postgres", "SQL dialect: postgres or sqlite")
+	table := flags.String("table", "", "table holding the status column (defaults to the automaton's name)")
+	column := flags.String("column", "status", "status column following the automaton")
+	output := flags.String("o", "", "write to this file instead of <file>_stateflow.sql")
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, genUsage)
+		return 1
+	}
+
+	defs, status := loadTarget(positional[0])
+	if defs == nil {
+		return status
+	}
+	if *output == "" {
+		filename, _ := splitTarget(positional[0])
+		*output = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)) + "_stateflow.sql"
+	}
+
+	var code bytes.Buffer
+	if err := stateflow.GenerateSQL(&code, *dialect, *table, *column, defs); err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating code: %v\n", err)
+		return 1
+	}
+	return writeFiles(map[string][]byte{*output: code.Bytes()})
+}
+
+// Writes every file, stopping at the first error
+func writeFiles(files map[string][]byte) int {
+	for name, contents := range files {
+		if err := os.WriteFile(name, contents, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing file: %v\n", err)
+			return 1
+		}
+	}
+	return 0
+}
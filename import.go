@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jposo/stateflow/stateflow"
+)
+
+// runImport converts automata written in another format into a .sf file
+func runImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	from := flags.String("from", "", "input format: scxml, jflap or dot")
+	output := flags.String("o", "", "write to this file instead of stdout")
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 1 || *from == "" {
+		fmt.Fprintln(os.Stderr, "Usage: stateflow import --from <format> [-o <output.sf>] <filename>")
+		return 1
+	}
+
+	file, err := os.Open(positional[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+		return 1
+	}
+	defer file.Close()
+
+	name := strings.TrimSuffix(filepath.Base(positional[0]), filepath.Ext(positional[0]))
+	source, err := stateflow.Import(file, *from, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error importing %s: %v\n", positional[0], err)
+		return 65
+	}
+
+	if *output == "" {
+		fmt.Print(string(source))
+		return 0
+	}
+	if err := os.WriteFile(*output, source, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing file: %v\n", err)
+		return 1
+	}
+	return 0
+}
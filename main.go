@@ -1,20 +1,93 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
 	"github.com/jposo/stateflow/stateflow"
 )
 
+const usage = `Usage:
+  stateflow tokenize <filename>
+  stateflow parse [--dump-ast json] <filename>
+  stateflow fmt [--check] [--diff] <filename>...
+  stateflow lsp
+  stateflow repl [filename]...
+  stateflow run [--trace] [--json] <filename>[:automaton] <input>...
+  stateflow debug <filename>[:automaton] --input <input>...
+  stateflow bench [-inputs <file>] [-time <duration>] <filename>[:automaton]
+  stateflow stream [-state <file>] <filename>[:automaton] [<input file>...]
+  stateflow export --format dot|mermaid|plantuml|scxml|jflap|tikz|graphml|json [-o <output>] <filename>[:automaton]
+  stateflow render [-o <output.svg>] <filename>[:automaton]
+  stateflow show [--matrix|--diagram] [--ascii] <filename>[:automaton]
+  stateflow import --from scxml|jflap|dot [-o <output.sf>] <filename>
+  stateflow gen go [-pkg <package>] [-workflow] [-o <output.go>] [-tests=false] <filename>[:automaton]
+  stateflow gen c [-o <output.c>] [-tests=false] <filename>[:automaton]
+  stateflow gen ts [-o <output.ts>] <filename>[:automaton]
+  stateflow gen python [-o <output.py>] [-tests=false] <filename>[:automaton]
+  stateflow gen sql [-dialect postgres|sqlite] [-table <table>] [-column <column>] [-o <output.sql>] <filename>[:automaton]`
+
 func main() {
-	if len(os.Args) < 3 {
-		fmt.Fprintln(os.Stderr, "Usage: stateflow (tokenize | parse) <filename>")
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
 		os.Exit(1)
 	}
 	op := os.Args[1]
 
-	filename := os.Args[2]
+	switch op {
+	case "fmt":
+		os.Exit(runFmt(os.Args[2:]))
+	case "run":
+		os.Exit(runRun(os.Args[2:]))
+	case "debug":
+		os.Exit(runDebug(os.Args[2:]))
+	case "bench":
+		os.Exit(runBench(os.Args[2:]))
+	case "stream":
+		os.Exit(runStream(os.Args[2:]))
+	case "export":
+		os.Exit(runExport(os.Args[2:]))
+	case "render":
+		os.Exit(runRender(os.Args[2:]))
+	case "show":
+		os.Exit(runShow(os.Args[2:]))
+	case "import":
+		os.Exit(runImport(os.Args[2:]))
+	case "gen":
+		os.Exit(runGen(os.Args[2:]))
+	case "lsp":
+		if err := stateflow.ServeLSP(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Language server error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	case "repl":
+		repl := stateflow.NewREPL(os.Stdout)
+		for _, filename := range os.Args[2:] {
+			repl.Eval(":load " + filename)
+		}
+		if err := repl.Run(os.Stdin); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if len(os.Args) < 3 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	flags := flag.NewFlagSet(op, flag.ExitOnError)
+	dumpAst := flags.String("dump-ast", "", "print the parsed AST in the given format (json)")
+	args := parseInterspersed(flags, os.Args[2:])
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	filename := args[0]
 	fileContents, err := os.ReadFile(filename)
 
 	if err != nil {
@@ -36,16 +109,25 @@ func main() {
 		scanner.PrintTokens()
 	case "parse":
 		parser := stateflow.Parser{Tokens: tokens}
-		_, parseErr := parser.Parse()
+		defs, parseErr := parser.Parse()
 		if parseErr != nil {
 			fmt.Fprint(os.Stderr, parseErr.Error())
 			os.Exit(65) // Syntax or Semantics Error
 		}
-		fmt.Println("No errors!")
-		// for _, def := range defs {
-		// 	fmt.Println(def)
-		// }
-		// printer := stateflow.AstPrinter{}
+		switch *dumpAst {
+		case "":
+			fmt.Println("No errors!")
+		case "json":
+			data, err := stateflow.MarshalAST(defs)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error encoding AST: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(data))
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown AST format '%s'.\n", *dumpAst)
+			os.Exit(1)
+		}
 
 	default:
 		fmt.Fprintf(os.Stderr, "Invalid operation.")
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jposo/stateflow/stateflow"
+)
+
+// splitTarget splits `file.sf:name` into the file and the definition name.
+// The name is empty when the argument is just a file.
+func splitTarget(target string) (string, string) {
+	i := strings.LastIndex(target, ":")
+	// A colon right after a drive letter belongs to the path
+	if i <= 1 || strings.ContainsAny(target[i+1:], `/\.`) {
+		return target, ""
+	}
+	return target[:i], target[i+1:]
+}
+
+// loadProgram scans and parses a file, printing any error to stderr. The
+// second result is the exit status to use when the program is nil.
+func loadProgram(filename string) ([]stateflow.Definition, int) {
+	fileContents, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+		return nil, 1
+	}
+
+	scanner := stateflow.Scanner{Source: fileContents}
+	tokens, scanErrs := scanner.ScanTokens()
+	if len(scanErrs) > 0 {
+		for _, err := range scanErrs {
+			fmt.Fprint(os.Stderr, err.Error())
+		}
+		return nil, 65 // Lexical Error
+	}
+
+	parser := stateflow.Parser{Tokens: tokens}
+	defs, err := parser.Parse()
+	if err != nil {
+		fmt.Fprint(os.Stderr, err.Error())
+		return nil, 65 // Syntax or Semantics Error
+	}
+	return defs, 0
+}
+
+// loadTarget loads the definitions of `file.sf`, or only the definition
+// named by `file.sf:name`
+func loadTarget(target string) ([]stateflow.Definition, int) {
+	filename, name := splitTarget(target)
+	defs, status := loadProgram(filename)
+	if defs == nil || name == "" {
+		return defs, status
+	}
+	for _, def := range defs {
+		if named, ok := def.(interface{ Name() string }); ok && named.Name() == name {
+			return []stateflow.Definition{def}, 0
+		}
+	}
+	fmt.Fprintf(os.Stderr, "No definition named '%s' in %s.\n", name, filename)
+	return nil, 1
+}
+
+// parseInterspersed parses flags that may appear before, between or after
+// positional arguments, and returns the positional arguments
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jposo/stateflow/stateflow"
+)
+
+// runRender draws the automata of a file, or the one named by
+// `file.sf:name`, as an SVG image
+func runRender(args []string) int {
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	output := flags.String("o", "", "write to this file instead of stdout")
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: stateflow render [-o <output.svg>] <filename>[:automaton]")
+		return 1
+	}
+
+	defs, status := loadTarget(positional[0])
+	if defs == nil {
+		return status
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating file: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if err := stateflow.RenderSVG(w, defs); err != nil {
+		fmt.Fprintf(os.Stderr, "Error rendering: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jposo/stateflow/stateflow"
+)
+
+// runRun runs a program over the given inputs. A target of the form
+// `file.sf:name` runs that automaton over each input; a bare file calls
+// its `main` function with the inputs as arguments. The exit status is 1
+// when any input is rejected.
+func runRun(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	trace := flags.Bool("trace", false, "print every step taken and why an input was rejected")
+	asJSON := flags.Bool("json", false, "print the traces as JSON")
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: stateflow run [--trace] [--json] <filename>[:automaton] <input>...")
+		return 1
+	}
+
+	filename, name := splitTarget(flags.Arg(0))
+	defs, status := loadProgram(filename)
+	if defs == nil {
+		return status
+	}
+	interpreter := stateflow.NewInterpreter()
+	if err := interpreter.Define(defs); err != nil {
+		fmt.Fprint(os.Stderr, err.Error())
+		return 70 // Runtime Error
+	}
+
+	var results []stateflow.Result
+	inputs := flags.Args()[1:]
+	if name == "" {
+		var err error
+		results, err = interpreter.Call("main", inputs)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, strings.TrimRight(err.Error(), "\n"))
+			return 70 // Runtime Error
+		}
+	} else {
+		automaton := interpreter.Automaton(name)
+		if automaton == nil {
+			fmt.Fprintf(os.Stderr, "Unknown automaton '%s'.\n", name)
+			return 1
+		}
+		for _, input := range inputs {
+			results = append(results, automaton.Run(input))
+		}
+	}
+
+	status = 0
+	for _, result := range results {
+		if !result.Accepted {
+			status = 1
+		}
+	}
+
+	if *asJSON {
+		data, err := stateflow.MarshalTraces(results)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding trace: %v\n", err)
+			return 1
+		}
+		fmt.Println(string(data))
+		return status
+	}
+
+	for i, result := range results {
+		if *trace {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s <- %q\n", result.Automaton, result.Input)
+			stateflow.WriteTrace(os.Stdout, result)
+			continue
+		}
+		if result.Accepted {
+			fmt.Printf("%s <- %q: accepted\n", result.Automaton, result.Input)
+		} else {
+			fmt.Printf("%s <- %q: rejected, %s\n", result.Automaton, result.Input, result.Rejection)
+		}
+	}
+	return status
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jposo/stateflow/stateflow"
+)
+
+// runShow draws the automata of a file, or the one named by
+// `file.sf:name`, in the terminal
+func runShow(args []string) int {
+	flags := flag.NewFlagSet("show", flag.ExitOnError)
+	matrix := flags.Bool("matrix", false, "always draw a transition matrix")
+	diagram := flags.Bool("diagram", false, "always draw a box diagram")
+	ascii := flags.Bool("ascii", false, "use only ASCII characters")
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 1 || (*matrix && *diagram) {
+		fmt.Fprintln(os.Stderr, "Usage: stateflow show [--matrix|--diagram] [--ascii] <filename>[:automaton]")
+		return 1
+	}
+
+	defs, status := loadTarget(positional[0])
+	if defs == nil {
+		return status
+	}
+
+	style := stateflow.ShowAuto
+	if *matrix {
+		style = stateflow.ShowMatrix
+	} else if *diagram {
+		style = stateflow.ShowDiagram
+	}
+	if err := stateflow.Show(os.Stdout, defs, style, *ascii); err != nil {
+		fmt.Fprintf(os.Stderr, "Error showing: %v\n", err)
+		return 1
+	}
+	return 0
+}
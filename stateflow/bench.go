@@ -0,0 +1,97 @@
+package stateflow
+
+import (
+	"fmt"
+	"time"
+)
+
+// BenchResult compares how fast the interpreter and the compiled program
+// run an automaton over the same inputs
+type BenchResult struct {
+	Automaton   string
+	Engine      string // "byte table" or "flat arrays"
+	Inputs      int
+	Interpreted Throughput
+	Compiled    Throughput
+}
+
+// Throughput is how many runs over how many input bytes took how long
+type Throughput struct {
+	Runs    int
+	Bytes   int
+	Elapsed time.Duration
+}
+
+// RunsPerSecond returns the number of inputs matched per second
+func (t Throughput) RunsPerSecond() float64 {
+	return float64(t.Runs) / t.Elapsed.Seconds()
+}
+
+// BytesPerSecond returns the number of input bytes matched per second
+func (t Throughput) BytesPerSecond() float64 {
+	return float64(t.Bytes) / t.Elapsed.Seconds()
+}
+
+// Speedup returns how many times faster the compiled program ran
+func (r BenchResult) Speedup() float64 {
+	return r.Compiled.RunsPerSecond() / r.Interpreted.RunsPerSecond()
+}
+
+// Bench runs every automaton among the definitions over the inputs, both
+// interpreted and compiled, for about the given duration each. Without
+// inputs, each automaton gets inputs covering all of its transitions. The
+// compiled verdicts are checked against the interpreter's first.
+func Bench(defs []Definition, inputs []string, duration time.Duration) ([]BenchResult, error) {
+	var results []BenchResult
+	for _, def := range defs {
+		automatonDef, ok := def.(*AutomatonDef)
+		if !ok {
+			continue
+		}
+		automaton, err := NewAutomaton(automatonDef)
+		if err != nil {
+			return nil, err
+		}
+		m := compileMachine(automaton)
+		samples := inputs
+		if samples == nil {
+			samples = sampleInputs(automaton)
+		}
+		if len(samples) == 0 {
+			return nil, fmt.Errorf("no inputs to benchmark '%s' with", automaton.Name)
+		}
+		for _, input := range samples {
+			if m.match(input) != automaton.Run(input).Accepted {
+				return nil, fmt.Errorf("compiled '%s' disagrees with the interpreter on %q", automaton.Name, input)
+			}
+		}
+
+		result := BenchResult{Automaton: automaton.Name, Engine: "flat arrays", Inputs: len(samples)}
+		if _, ok := m.(*matchTable); ok {
+			result.Engine = "byte table"
+		}
+		result.Interpreted = measure(samples, duration, func(input string) { automaton.Run(input) })
+		result.Compiled = measure(samples, duration, func(input string) { m.match(input) })
+		results = append(results, result)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no automaton to benchmark")
+	}
+	return results, nil
+}
+
+// Runs over the inputs again and again until the duration has passed,
+// checking the clock after each pass
+func measure(inputs []string, duration time.Duration, run func(string)) Throughput {
+	var t Throughput
+	start := time.Now()
+	for t.Elapsed < duration {
+		for _, input := range inputs {
+			run(input)
+			t.Bytes += len(input)
+		}
+		t.Runs += len(inputs)
+		t.Elapsed = time.Since(start)
+	}
+	return t
+}
@@ -0,0 +1,327 @@
+package stateflow
+
+import (
+	"strings"
+)
+
+type TriviaKind string
+
+const (
+	TriviaWhitespace TriviaKind = "WHITESPACE"
+	TriviaNewline    TriviaKind = "NEWLINE"
+	TriviaComment    TriviaKind = "COMMENT"
+	// Bytes the scanner rejected, kept so the file still round-trips
+	TriviaSkipped TriviaKind = "SKIPPED"
+)
+
+// Trivia is source text that carries no meaning for the parser
+type Trivia struct {
+	Kind   TriviaKind
+	Text   string
+	Offset int
+	Line   int
+	Column int
+}
+
+// CSTToken is a token of the lossless stream together with the trivia
+// around it. Leading trivia is everything between the previous token and
+// this one that was not claimed as trailing trivia; trailing trivia is the
+// whitespace and comments that follow the token on the same line.
+type CSTToken struct {
+	Token    Token
+	Offset   int
+	Inserted bool // Semicolon inserted by the scanner at a newline
+	Leading  []Trivia
+	Trailing []Trivia
+}
+
+// Text returns the source text of the token itself, without trivia.
+// Inserted semicolons have no text of their own.
+func (t *CSTToken) Text() string {
+	if t.Inserted {
+		return ""
+	}
+	return t.Token.lexeme
+}
+
+func (t *CSTToken) writeTo(b *strings.Builder) {
+	for _, trivia := range t.Leading {
+		b.WriteString(trivia.Text)
+	}
+	b.WriteString(t.Text())
+	for _, trivia := range t.Trailing {
+		b.WriteString(trivia.Text)
+	}
+}
+
+type NodeKind string
+
+const (
+	NodeFile         NodeKind = "File"
+	NodeAutomatonDef NodeKind = "AutomatonDef"
+	NodeFunctionDef  NodeKind = "FunctionDef"
+	NodeStateDecl    NodeKind = "StateDecl"
+	NodeTransDecl    NodeKind = "TransDecl"
+	NodeCall         NodeKind = "Call"
+	// Tokens that do not fit anywhere in the grammar
+	NodeError NodeKind = "Error"
+)
+
+// CSTElement is either a *CSTNode or a *CSTToken
+type CSTElement interface {
+	writeTo(b *strings.Builder)
+}
+
+// CSTNode is an interior node of the concrete syntax tree
+type CSTNode struct {
+	Kind     NodeKind
+	Children []CSTElement
+}
+
+func (n *CSTNode) writeTo(b *strings.Builder) {
+	for _, child := range n.Children {
+		child.writeTo(b)
+	}
+}
+
+// String reconstructs the exact source text the node was parsed from
+func (n *CSTNode) String() string {
+	var b strings.Builder
+	n.writeTo(&b)
+	return b.String()
+}
+
+// Tokens returns the tokens under the node in source order
+func (n *CSTNode) Tokens() []*CSTToken {
+	var tokens []*CSTToken
+	for _, child := range n.Children {
+		switch c := child.(type) {
+		case *CSTToken:
+			tokens = append(tokens, c)
+		case *CSTNode:
+			tokens = append(tokens, c.Tokens()...)
+		}
+	}
+	return tokens
+}
+
+// Nodes returns the direct children of the node that are nodes
+func (n *CSTNode) Nodes() []*CSTNode {
+	var nodes []*CSTNode
+	for _, child := range n.Children {
+		if node, ok := child.(*CSTNode); ok {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// ScanLossless scans the source like ScanTokens but keeps every byte:
+// whitespace, newlines, comments and rejected characters become trivia
+// attached to the surrounding tokens, and semicolons inserted at newlines
+// are marked as such. Concatenating the tokens with their trivia yields the
+// original source.
+func (s *Scanner) ScanLossless() ([]*CSTToken, []error) {
+	s.start = 0
+	s.current = 0
+	s.line = 1
+	s.lineStart = 0
+
+	bof := &CSTToken{Token: Token{BOF, "", 1, 1}}
+	stream := []*CSTToken{bof}
+	var pending []Trivia
+	// Trivia after the last token on its line is trailing trivia
+	sameLine := false
+
+	addTrivia := func(kind TriviaKind, text string, offset, line, column int) {
+		if n := len(pending); n > 0 && kind == TriviaWhitespace && pending[n-1].Kind == TriviaWhitespace {
+			pending[n-1].Text += text
+			return
+		}
+		pending = append(pending, Trivia{kind, text, offset, line, column})
+	}
+	flushTrailing := func() {
+		last := stream[len(stream)-1]
+		last.Trailing = append(last.Trailing, pending...)
+		pending = nil
+	}
+
+	for !s.isAtEnd() {
+		s.start = s.current
+		s.column = s.start - s.lineStart + 1
+		line := s.line
+		count := len(s.tokens)
+		err := s.scanToken()
+		text := string(s.Source[s.start:s.current])
+
+		if err != nil {
+			s.errors = append(s.errors, err)
+			addTrivia(TriviaSkipped, text, s.start, line, s.column)
+			continue
+		}
+
+		if len(s.tokens) == count {
+			switch {
+			case text == "\n":
+				if sameLine {
+					flushTrailing()
+				}
+				sameLine = false
+				addTrivia(TriviaNewline, text, s.start, line, s.column)
+			case strings.HasPrefix(text, "//"):
+				addTrivia(TriviaComment, text, s.start, line, s.column)
+			default:
+				addTrivia(TriviaWhitespace, text, s.start, line, s.column)
+			}
+			continue
+		}
+
+		token := s.tokens[count]
+		if token.tokenType == SEMICOLON && token.lexeme == "\n" {
+			if sameLine {
+				flushTrailing()
+			}
+			stream = append(stream, &CSTToken{
+				Token:    token,
+				Offset:   s.start,
+				Inserted: true,
+				Leading:  pending,
+			})
+			pending = nil
+			sameLine = false
+			addTrivia(TriviaNewline, text, s.start, line, s.column)
+			continue
+		}
+
+		if sameLine {
+			flushTrailing()
+		}
+		stream = append(stream, &CSTToken{Token: token, Offset: s.start, Leading: pending})
+		pending = nil
+		sameLine = !strings.Contains(text, "\n")
+	}
+
+	if sameLine {
+		flushTrailing()
+	}
+	eofToken := Token{EOF, "", s.line, s.current - s.lineStart + 1}
+	stream = append(stream, &CSTToken{Token: eofToken, Offset: s.current, Leading: pending})
+	s.tokens = append(s.tokens, eofToken)
+	return stream, s.errors
+}
+
+// ParseCST builds a concrete syntax tree that round-trips the source byte
+// for byte. The tree only groups tokens into definitions and statements;
+// it never fails, and anything that does not fit is wrapped in an Error
+// node. Use Parser for validation.
+func ParseCST(source []byte) (*CSTNode, []error) {
+	scanner := Scanner{Source: source}
+	tokens, errs := scanner.ScanLossless()
+	builder := cstBuilder{tokens: tokens}
+	return builder.file(), errs
+}
+
+type cstBuilder struct {
+	tokens  []*CSTToken
+	current int
+}
+
+func (b *cstBuilder) peek() TokenType {
+	return b.tokens[b.current].Token.tokenType
+}
+
+func (b *cstBuilder) advance() *CSTToken {
+	token := b.tokens[b.current]
+	if token.Token.tokenType != EOF {
+		b.current++
+	}
+	return token
+}
+
+// Appends the current token to the node if it has one of the given types
+func (b *cstBuilder) take(node *CSTNode, types ...TokenType) bool {
+	for _, t := range types {
+		if b.peek() == t {
+			node.Children = append(node.Children, b.advance())
+			return true
+		}
+	}
+	return false
+}
+
+// Appends tokens to the node up to and including the next semicolon,
+// stopping early before a closing brace or the end of file
+func (b *cstBuilder) untilSemicolon(node *CSTNode) {
+	for b.peek() != EOF && b.peek() != RIGHT_BRACE {
+		if b.take(node, SEMICOLON) {
+			return
+		}
+		node.Children = append(node.Children, b.advance())
+	}
+}
+
+func (b *cstBuilder) file() *CSTNode {
+	file := &CSTNode{Kind: NodeFile}
+	b.take(file, BOF)
+	for b.peek() != EOF {
+		switch b.peek() {
+		case DFA, NFA:
+			file.Children = append(file.Children, b.automatonDef())
+		case FUNCTION:
+			file.Children = append(file.Children, b.functionDef())
+		default:
+			file.Children = append(file.Children, &CSTNode{
+				Kind:     NodeError,
+				Children: []CSTElement{b.advance()},
+			})
+		}
+	}
+	b.take(file, EOF)
+	return file
+}
+
+func (b *cstBuilder) automatonDef() *CSTNode {
+	node := &CSTNode{Kind: NodeAutomatonDef}
+	b.take(node, DFA, NFA)
+	b.take(node, IDENTIFIER)
+	if !b.take(node, LEFT_BRACE) {
+		return node
+	}
+	for b.peek() != EOF && b.peek() != RIGHT_BRACE {
+		stmt := &CSTNode{Kind: NodeError}
+		switch b.peek() {
+		case INITIAL, STATE, FINAL:
+			stmt.Kind = NodeStateDecl
+		case ON:
+			stmt.Kind = NodeTransDecl
+		}
+		b.untilSemicolon(stmt)
+		node.Children = append(node.Children, stmt)
+	}
+	b.take(node, RIGHT_BRACE)
+	return node
+}
+
+func (b *cstBuilder) functionDef() *CSTNode {
+	node := &CSTNode{Kind: NodeFunctionDef}
+	b.take(node, FUNCTION)
+	b.take(node, IDENTIFIER)
+	if b.take(node, LEFT_PAREN) {
+		for b.take(node, IDENTIFIER, COMMA) {
+		}
+		b.take(node, RIGHT_PAREN)
+	}
+	if !b.take(node, LEFT_BRACE) {
+		return node
+	}
+	for b.peek() != EOF && b.peek() != RIGHT_BRACE {
+		statement := &CSTNode{Kind: NodeError}
+		if b.peek() == IDENTIFIER {
+			statement.Kind = NodeCall
+		}
+		b.untilSemicolon(statement)
+		node.Children = append(node.Children, statement)
+	}
+	b.take(node, RIGHT_BRACE)
+	return node
+}
@@ -0,0 +1,91 @@
+package stateflow
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCSTRoundTrip(t *testing.T) {
+	sources := []string{
+		"",
+		"\n\n  // only a comment",
+		"dfa a{initial q0;final q1;on q0->q1 when \"x\" or /[a-z]/;}",
+		"dfa automataExample { // dfa\r\n  initial q0\r\n\tfinal q1 // trailing\n  on q0 -> q1 when \"1\"\n}\n",
+		"fn main(input, other) {\n  a <- input\n}\n\n",
+		"dfa broken {\n  on q0 - q1 when \"unterminated ;\n}",
+		"} ; stray -> tokens ?",
+	}
+	for _, source := range sources {
+		root, _ := ParseCST([]byte(source))
+		if got := root.String(); got != source {
+			t.Errorf("Round trip mismatch:\nexpected %q\ngot      %q", source, got)
+		}
+	}
+}
+
+func TestCSTRoundTripExamples(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "examples", "*.sf"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("Expected example files, got %v", err)
+	}
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root, _ := ParseCST(source)
+		if root.String() != string(source) {
+			t.Errorf("%s: round trip mismatch", file)
+		}
+	}
+}
+
+func TestCSTInsertedSemicolons(t *testing.T) {
+	source := "dfa a {\n  final q0 // done\n  state q1;\n}"
+	scanner := Scanner{Source: []byte(source)}
+	tokens, errors := scanner.ScanLossless()
+	if len(errors) != 0 {
+		t.Fatalf("Expected no errors, got %d", len(errors))
+	}
+
+	var semicolons []*CSTToken
+	for _, token := range tokens {
+		if token.Token.tokenType == SEMICOLON {
+			semicolons = append(semicolons, token)
+		}
+	}
+	if len(semicolons) != 2 {
+		t.Fatalf("Expected 2 semicolons, got %d", len(semicolons))
+	}
+	if !semicolons[0].Inserted || semicolons[0].Text() != "" {
+		t.Errorf("Expected first semicolon to be inserted")
+	}
+	if semicolons[1].Inserted || semicolons[1].Text() != ";" {
+		t.Errorf("Expected second semicolon to be written")
+	}
+
+	// The comment trails q0 instead of leading the next statement
+	q0 := tokens[5]
+	if q0.Token.lexeme != "q0" || len(q0.Trailing) != 2 || q0.Trailing[1].Kind != TriviaComment {
+		t.Errorf("Expected comment as trailing trivia of q0, got %+v", q0)
+	}
+}
+
+func TestCSTStructure(t *testing.T) {
+	source := "dfa a {\n  initial q0;\n  on q0 -> q0 when \"a\";\n}\nfn main(x) { a <- x; }"
+	root, _ := ParseCST([]byte(source))
+
+	defs := root.Nodes()
+	if len(defs) != 2 || defs[0].Kind != NodeAutomatonDef || defs[1].Kind != NodeFunctionDef {
+		t.Fatalf("Expected automaton and function nodes, got %+v", defs)
+	}
+	stmts := defs[0].Nodes()
+	if len(stmts) != 2 || stmts[0].Kind != NodeStateDecl || stmts[1].Kind != NodeTransDecl {
+		t.Errorf("Expected state and transition nodes, got %+v", stmts)
+	}
+	calls := defs[1].Nodes()
+	if len(calls) != 1 || calls[0].Kind != NodeCall {
+		t.Errorf("Expected one call node, got %+v", calls)
+	}
+}
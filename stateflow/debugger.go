@@ -0,0 +1,348 @@
+package stateflow
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const debuggerHelp = `Commands:
+  step [n], s        consume the next symbol (n times)
+  continue, c        run until a breakpoint or the end of the program
+  break state <s>    pause when a run enters state s (or automaton.s)
+  break trans <a>-><b>
+                     pause when a transition from a to b fires
+  break line <n>     pause when the transition declared on line n fires
+  breakpoints        list breakpoints
+  delete [id]        delete a breakpoint, or all of them
+  info, i            show the current run and its state set
+  rewind [n], r      undo the last step (n steps)
+  restart            go back to the start of the program
+  help               show this help
+  quit, q            leave the debugger
+`
+
+type breakpointKind string
+
+const (
+	breakOnState      breakpointKind = "state"
+	breakOnTransition breakpointKind = "trans"
+	breakOnLine       breakpointKind = "line"
+)
+
+// breakpoint pauses execution when a state is entered or a transition
+// fires. States and transitions may be qualified with an automaton name.
+type breakpoint struct {
+	id        int
+	kind      breakpointKind
+	automaton string // Empty matches any automaton
+	from, to  string
+	line      int
+}
+
+func (b breakpoint) String() string {
+	prefix := ""
+	if b.automaton != "" {
+		prefix = b.automaton + "."
+	}
+	switch b.kind {
+	case breakOnState:
+		return fmt.Sprintf("#%d enter state %s%s", b.id, prefix, b.to)
+	case breakOnTransition:
+		return fmt.Sprintf("#%d transition %s%s -> %s", b.id, prefix, b.from, b.to)
+	}
+	return fmt.Sprintf("#%d transition on line %d", b.id, b.line)
+}
+
+// Reports whether the breakpoint matches entering a state through a step,
+// or entering the initial state when the step is nil
+func (b breakpoint) matches(automaton string, state string, step *Step) bool {
+	if b.automaton != "" && b.automaton != automaton {
+		return false
+	}
+	switch b.kind {
+	case breakOnState:
+		return b.to == state
+	case breakOnTransition:
+		return step != nil && step.From == b.from && step.To == b.to
+	}
+	return step != nil && step.Transition.Line() == b.line
+}
+
+// Debugger steps through the automaton runs requested by a program, one
+// symbol at a time, across every call statement of a function
+type Debugger struct {
+	out         io.Writer
+	invocations []Invocation
+	current     int // Index of the invocation being run
+	sim         *Simulation
+	results     []Result // Results of the finished invocations
+	history     []debuggerSnapshot
+	breakpoints []breakpoint
+	nextID      int
+}
+
+type debuggerSnapshot struct {
+	current int
+	sim     *Simulation
+	results []Result
+}
+
+// NewDebugger prepares to debug the given automaton runs
+func NewDebugger(out io.Writer, invocations []Invocation) *Debugger {
+	d := &Debugger{out: out, invocations: invocations, nextID: 1}
+	d.restart()
+	return d
+}
+
+func (d *Debugger) restart() {
+	d.current = 0
+	d.results = nil
+	d.history = nil
+	d.sim = nil
+	if len(d.invocations) > 0 {
+		d.sim = NewSimulation(d.invocations[0].Automaton, d.invocations[0].Input)
+	}
+}
+
+func (d *Debugger) finished() bool {
+	return d.current >= len(d.invocations)
+}
+
+// Run reads commands until the input ends or the user quits
+func (d *Debugger) Run(in io.Reader) error {
+	lines := bufio.NewScanner(in)
+	d.info()
+	fmt.Fprint(d.out, "(sfdb) ")
+	for lines.Scan() {
+		if !d.Eval(lines.Text()) {
+			return nil
+		}
+		fmt.Fprint(d.out, "(sfdb) ")
+	}
+	fmt.Fprintln(d.out)
+	return lines.Err()
+}
+
+// Eval handles one command and reports whether the session goes on
+func (d *Debugger) Eval(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return true
+	}
+	command, args := fields[0], fields[1:]
+
+	count := 1
+	if command == "step" || command == "s" || command == "rewind" || command == "r" {
+		if len(args) > 1 {
+			fmt.Fprintf(d.out, "Usage: %s [n]\n", command)
+			return true
+		}
+		if len(args) == 1 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < 1 {
+				fmt.Fprintln(d.out, "Expect a positive number of steps.")
+				return true
+			}
+			count = n
+		}
+	}
+
+	switch command {
+	case "quit", "q":
+		return false
+	case "help", "h":
+		fmt.Fprint(d.out, debuggerHelp)
+	case "step", "s":
+		for range count {
+			if d.finished() {
+				fmt.Fprintln(d.out, "The program has finished.")
+				break
+			}
+			d.step()
+		}
+		d.info()
+	case "continue", "c":
+		if d.finished() {
+			fmt.Fprintln(d.out, "The program has finished.")
+			break
+		}
+		for !d.finished() {
+			if hits := d.step(); len(hits) > 0 {
+				for _, hit := range hits {
+					fmt.Fprintf(d.out, "Breakpoint %s\n", hit)
+				}
+				break
+			}
+		}
+		d.info()
+	case "break", "b":
+		d.addBreakpoint(args)
+	case "breakpoints":
+		if len(d.breakpoints) == 0 {
+			fmt.Fprintln(d.out, "No breakpoints.")
+		}
+		for _, b := range d.breakpoints {
+			fmt.Fprintln(d.out, b)
+		}
+	case "delete", "d":
+		if len(args) == 0 {
+			d.breakpoints = nil
+			break
+		}
+		id, _ := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+		index := slices.IndexFunc(d.breakpoints, func(b breakpoint) bool { return b.id == id })
+		if index < 0 {
+			fmt.Fprintf(d.out, "No breakpoint %s.\n", args[0])
+			break
+		}
+		d.breakpoints = slices.Delete(d.breakpoints, index, index+1)
+	case "info", "i":
+		d.info()
+	case "rewind", "r":
+		if len(d.history) == 0 {
+			fmt.Fprintln(d.out, "Already at the start of the program.")
+			break
+		}
+		count = min(count, len(d.history))
+		snapshot := d.history[len(d.history)-count]
+		d.history = d.history[:len(d.history)-count]
+		d.current, d.sim, d.results = snapshot.current, snapshot.sim, snapshot.results
+		d.info()
+	case "restart":
+		d.restart()
+		d.info()
+	default:
+		fmt.Fprintf(d.out, "Unknown command '%s'. Type help for help.\n", command)
+	}
+	return true
+}
+
+func (d *Debugger) addBreakpoint(args []string) {
+	if len(args) != 2 {
+		fmt.Fprintln(d.out, "Usage: break state <s> | break trans <a>-><b> | break line <n>")
+		return
+	}
+	b := breakpoint{id: d.nextID, kind: breakpointKind(args[0])}
+	target := args[1]
+	if automaton, rest, ok := strings.Cut(target, "."); ok && b.kind != breakOnLine {
+		b.automaton, target = automaton, rest
+	}
+
+	switch b.kind {
+	case breakOnState:
+		b.to = target
+	case breakOnTransition:
+		from, to, ok := strings.Cut(target, "->")
+		if !ok || from == "" || to == "" {
+			fmt.Fprintln(d.out, "Usage: break trans <a>-><b>")
+			return
+		}
+		b.from, b.to = from, to
+	case breakOnLine:
+		line, err := strconv.Atoi(target)
+		if err != nil {
+			fmt.Fprintln(d.out, "Usage: break line <n>")
+			return
+		}
+		b.line = line
+	default:
+		fmt.Fprintln(d.out, "Usage: break state <s> | break trans <a>-><b> | break line <n>")
+		return
+	}
+	d.nextID++
+	d.breakpoints = append(d.breakpoints, b)
+	fmt.Fprintf(d.out, "Breakpoint %s\n", b)
+}
+
+// Advances the program by one step and returns the breakpoints it hit.
+// A finished run moves on to the next invocation, entering its initial
+// state.
+func (d *Debugger) step() []breakpoint {
+	d.history = append(d.history, debuggerSnapshot{d.current, d.sim.clone(), slices.Clone(d.results)})
+	automaton := d.invocations[d.current].Automaton
+
+	if d.sim.Done() {
+		result := d.sim.Result()
+		d.results = append(d.results, result)
+		verdict := "accepted"
+		if !result.Accepted {
+			verdict = "rejected, " + result.Rejection.String()
+		}
+		fmt.Fprintf(d.out, "%s <- %q: %s\n", automaton.Name, result.Input, verdict)
+
+		d.current++
+		if d.finished() {
+			d.sim = nil
+			return nil
+		}
+		next := d.invocations[d.current]
+		d.sim = NewSimulation(next.Automaton, next.Input)
+		return d.hits(next.Automaton.Name, next.Automaton.Initial, nil)
+	}
+
+	var hits []breakpoint
+	for _, step := range d.sim.Step() {
+		fmt.Fprintf(d.out, "  %s --%q--> %s   (line %d)\n", step.From, step.Symbol, step.To, step.Transition.Line())
+		for _, hit := range d.hits(automaton.Name, step.To, &step) {
+			if !slices.ContainsFunc(hits, func(b breakpoint) bool { return b.id == hit.id }) {
+				hits = append(hits, hit)
+			}
+		}
+	}
+	return hits
+}
+
+func (d *Debugger) hits(automaton string, state string, step *Step) []breakpoint {
+	var hits []breakpoint
+	for _, b := range d.breakpoints {
+		if b.matches(automaton, state, step) {
+			hits = append(hits, b)
+		}
+	}
+	return hits
+}
+
+// Shows the current run, how much input it consumed and its state set
+func (d *Debugger) info() {
+	if d.finished() {
+		fmt.Fprintln(d.out, "The program has finished.")
+		for _, result := range d.results {
+			verdict := "accepted"
+			if !result.Accepted {
+				verdict = "rejected"
+			}
+			fmt.Fprintf(d.out, "  %s <- %q: %s\n", result.Automaton, result.Input, verdict)
+		}
+		return
+	}
+
+	invocation := d.invocations[d.current]
+	fmt.Fprintf(d.out, "[%d/%d] %s <- %q", d.current+1, len(d.invocations), invocation.Automaton.Name, invocation.Input)
+	if line := invocation.Line(); line > 0 {
+		fmt.Fprintf(d.out, " (line %d)", line)
+	}
+	fmt.Fprintln(d.out)
+
+	configs := d.sim.Configurations()
+	if len(configs) == 0 {
+		fmt.Fprintln(d.out, "  no live states, the input is rejected")
+		return
+	}
+	var states []string
+	for _, config := range configs {
+		marker := ""
+		if invocation.Automaton.IsFinal(config.State) {
+			marker = "*"
+		}
+		states = append(states, fmt.Sprintf("%s%s@%d", config.State, marker, config.Pos))
+	}
+	fmt.Fprintf(d.out, "  states: {%s}\n", strings.Join(states, ", "))
+	if len(configs) == 1 {
+		pos := configs[0].Pos
+		fmt.Fprintf(d.out, "  input:  %s|%s\n", invocation.Input[:pos], invocation.Input[pos:])
+	}
+}
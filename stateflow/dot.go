@@ -0,0 +1,60 @@
+package stateflow
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// exportDOT writes every automaton as a Graphviz digraph. The initial state
+// gets an entry arrow from an invisible point, final states are double
+// circles, parallel transitions share one edge whose label joins the
+// conditions with 'or', and regex conditions are set in italics.
+func exportDOT(w io.Writer, graphs []*automatonGraph) error {
+	var b strings.Builder
+	for i, graph := range graphs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "digraph %s {\n", strconv.Quote(graph.name))
+		fmt.Fprintf(&b, "  label=%s;\n", strconv.Quote(strings.ToLower(string(graph.kind))+" "+graph.name))
+		b.WriteString("  rankdir=LR;\n")
+		b.WriteString("  node [shape=circle];\n")
+
+		for _, state := range graph.states {
+			attrs := ""
+			if state.kind == FINAL {
+				attrs = " [shape=doublecircle]"
+			}
+			fmt.Fprintf(&b, "  %s%s;\n", strconv.Quote(state.name), attrs)
+			if state.kind == INITIAL {
+				start := strconv.Quote("__start_" + state.name)
+				fmt.Fprintf(&b, "  %s [shape=point, label=\"\"];\n", start)
+				fmt.Fprintf(&b, "  %s -> %s;\n", start, strconv.Quote(state.name))
+			}
+		}
+
+		for _, edge := range graph.edges {
+			fmt.Fprintf(&b, "  %s -> %s [label=<%s>];\n", strconv.Quote(edge.from), strconv.Quote(edge.to), dotLabel(edge.conditions))
+		}
+		b.WriteString("}\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Builds an HTML-like label, with regex conditions in italics
+func dotLabel(conditions []Condition) string {
+	var parts []string
+	for _, condition := range conditions {
+		text := dotEscaper.Replace(conditionText(condition))
+		if _, ok := condition.(RegexCondition); ok {
+			text = "<I>" + text + "</I>"
+		}
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, " or ")
+}
+
+var dotEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
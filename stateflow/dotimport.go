@@ -0,0 +1,586 @@
+package stateflow
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// DOT files are read as automata following the usual conventions:
+//
+//   - a node with shape=doublecircle or peripheries=2, or with final=true,
+//     is final
+//   - the target of an edge from a node with shape=point or style=invis
+//     (which is dropped), or a node with initial=true, is initial; when
+//     nothing marks it, the first node is
+//   - edge labels are conditions, either written as in a transition
+//     (`"a" or /[0-9]/`) or as plain symbols separated by commas or
+//     newlines (`a, b`), each of which becomes a string
+//   - several edges between the same nodes are joined with 'or'
+//
+// Every digraph in the file becomes an automaton. A graph label such as
+// "nfa name", as written by the DOT exporter, sets the kind of automaton.
+
+// importDOT reads the digraphs of a DOT file
+func importDOT(r io.Reader, name string) ([]*sourceAutomaton, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	p := dotParser{lexer: dotLexer{source: string(data), line: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	var automata []*sourceAutomaton
+	for p.token.kind != dotEOF {
+		graph, err := p.graph()
+		if err != nil {
+			return nil, err
+		}
+		if graph.name == "" {
+			graph.name = name
+		}
+		automaton, err := graph.automaton()
+		if err != nil {
+			return nil, fmt.Errorf("digraph %s: %w", graph.name, err)
+		}
+		automata = append(automata, automaton)
+	}
+	if len(automata) == 0 {
+		return nil, fmt.Errorf("the file has no digraphs")
+	}
+	return automata, nil
+}
+
+type dotTokenKind int
+
+const (
+	dotEOF   dotTokenKind = iota
+	dotID                 // Identifier, number, quoted or HTML string
+	dotPunct              // One of { } [ ] ; , = : and the edge operators
+)
+
+type dotToken struct {
+	kind  dotTokenKind
+	text  string
+	html  bool // An HTML string, written between angle brackets
+	quote bool // A quoted string
+	line  int
+}
+
+// Reports whether the token is the given keyword, which DOT reads in any
+// case unless quoted
+func (t dotToken) is(keyword string) bool {
+	return t.kind == dotID && !t.quote && !t.html && strings.EqualFold(t.text, keyword)
+}
+
+type dotLexer struct {
+	source string
+	pos    int
+	line   int
+}
+
+func (l *dotLexer) next() (dotToken, error) {
+	// Skip space, comments and preprocessor lines
+	for l.pos < len(l.source) {
+		c := l.source[l.pos]
+		switch {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+		case strings.HasPrefix(l.source[l.pos:], "//") || (c == '#' && (l.pos == 0 || l.source[l.pos-1] == '\n')):
+			for l.pos < len(l.source) && l.source[l.pos] != '\n' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.source[l.pos:], "/*"):
+			end := strings.Index(l.source[l.pos+2:], "*/")
+			if end < 0 {
+				return dotToken{}, fmt.Errorf("line %d: unterminated comment", l.line)
+			}
+			l.line += strings.Count(l.source[l.pos:l.pos+2+end], "\n")
+			l.pos += end + 4
+		default:
+			return l.token()
+		}
+	}
+	return dotToken{kind: dotEOF, line: l.line}, nil
+}
+
+func (l *dotLexer) token() (dotToken, error) {
+	start, line := l.pos, l.line
+	c := l.source[l.pos]
+	switch {
+	case strings.HasPrefix(l.source[l.pos:], "->") || strings.HasPrefix(l.source[l.pos:], "--"):
+		l.pos += 2
+		return dotToken{kind: dotPunct, text: l.source[start:l.pos], line: line}, nil
+	case strings.ContainsRune("{}[];,=:", rune(c)):
+		l.pos++
+		return dotToken{kind: dotPunct, text: string(c), line: line}, nil
+	case c == '"':
+		var b strings.Builder
+		for l.pos++; l.pos < len(l.source); l.pos++ {
+			c := l.source[l.pos]
+			switch {
+			case c == '"':
+				l.pos++
+				return dotToken{kind: dotID, text: b.String(), quote: true, line: line}, nil
+			case c == '\\' && l.pos+1 < len(l.source) && l.source[l.pos+1] == '"':
+				b.WriteByte('"')
+				l.pos++
+			case c == '\\' && l.pos+1 < len(l.source) && l.source[l.pos+1] == '\n':
+				l.line++
+				l.pos++ // Line continuation
+			default:
+				if c == '\n' {
+					l.line++
+				}
+				b.WriteByte(c)
+			}
+		}
+		return dotToken{}, fmt.Errorf("line %d: unterminated string", line)
+	case c == '<':
+		depth := 0
+		for ; l.pos < len(l.source); l.pos++ {
+			switch l.source[l.pos] {
+			case '<':
+				depth++
+			case '>':
+				depth--
+			case '\n':
+				l.line++
+			}
+			if depth == 0 {
+				l.pos++
+				return dotToken{kind: dotID, text: l.source[start+1 : l.pos-1], html: true, line: line}, nil
+			}
+		}
+		return dotToken{}, fmt.Errorf("line %d: unterminated HTML string", line)
+	case isAlpha(c) || c >= 0x80:
+		for l.pos < len(l.source) && (isAlphanumeric(l.source[l.pos]) || l.source[l.pos] >= 0x80) {
+			l.pos++
+		}
+		return dotToken{kind: dotID, text: l.source[start:l.pos], line: line}, nil
+	case isDigit(c) || c == '.' || c == '-':
+		// A numeral, with an optional sign and decimal point
+		l.pos++
+		for l.pos < len(l.source) && (isDigit(l.source[l.pos]) || l.source[l.pos] == '.') {
+			l.pos++
+		}
+		return dotToken{kind: dotID, text: l.source[start:l.pos], line: line}, nil
+	}
+	return dotToken{}, fmt.Errorf("line %d: unexpected character '%c'", line, c)
+}
+
+// dotGraph is the part of a digraph the importer needs
+type dotGraph struct {
+	name      string
+	attrs     map[string]string
+	nodes     []string
+	nodeAttrs map[string]map[string]string
+	edges     []dotEdge
+}
+
+type dotEdge struct {
+	from, to string
+	attrs    map[string]string
+	line     int
+}
+
+// dotScope holds the default attributes set by `node [...]` and
+// `edge [...]`, which subgraphs inherit without changing their parent's
+type dotScope struct {
+	node, edge map[string]string
+}
+
+type dotParser struct {
+	lexer   dotLexer
+	token   dotToken
+	current *dotGraph // Graph being read
+	depth   int       // Nesting of subgraphs, whose graph attributes are ignored
+}
+
+func (p *dotParser) advance() error {
+	token, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.token = token
+	return nil
+}
+
+func (p *dotParser) expect(text string) error {
+	if p.token.kind != dotPunct || p.token.text != text {
+		return p.unexpected("'" + text + "'")
+	}
+	return p.advance()
+}
+
+func (p *dotParser) unexpected(expected string) error {
+	found := p.token.text
+	if p.token.kind == dotEOF {
+		found = "end of file"
+	}
+	return fmt.Errorf("line %d: expect %s, found '%s'", p.token.line, expected, found)
+}
+
+func (p *dotParser) punct(text string) bool {
+	return p.token.kind == dotPunct && p.token.text == text
+}
+
+// graph := [strict] digraph [ID] '{' stmts '}'
+func (p *dotParser) graph() (*dotGraph, error) {
+	if p.token.is("strict") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if p.token.is("graph") {
+		return nil, fmt.Errorf("line %d: undirected graphs cannot be imported, use digraph", p.token.line)
+	}
+	if !p.token.is("digraph") {
+		return nil, p.unexpected("'digraph'")
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	p.current = &dotGraph{attrs: make(map[string]string), nodeAttrs: make(map[string]map[string]string)}
+	if p.token.kind == dotID {
+		p.current.name = p.token.text
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.block(dotScope{map[string]string{}, map[string]string{}}); err != nil {
+		return nil, err
+	}
+	return p.current, nil
+}
+
+// Parses '{' stmts '}' and returns the nodes mentioned inside
+func (p *dotParser) block(scope dotScope) ([]string, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+	var nodes []string
+	for !p.punct("}") {
+		if p.token.kind == dotEOF {
+			return nil, p.unexpected("'}'")
+		}
+		mentioned, err := p.stmt(&scope)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, mentioned...)
+		if p.punct(";") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nodes, p.advance()
+}
+
+func (p *dotParser) stmt(scope *dotScope) ([]string, error) {
+	switch {
+	case p.token.is("graph") || p.token.is("node") || p.token.is("edge"):
+		kind := strings.ToLower(p.token.text)
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		attrs, err := p.attrList()
+		if err != nil {
+			return nil, err
+		}
+		target := map[string]map[string]string{"graph": p.current.attrs, "node": scope.node, "edge": scope.edge}[kind]
+		if kind == "graph" && p.depth > 1 {
+			target = map[string]string{}
+		} else if kind != "graph" {
+			// Copy so the enclosing scope keeps its defaults
+			target = cloneAttrs(target)
+			if kind == "node" {
+				scope.node = target
+			} else {
+				scope.edge = target
+			}
+		}
+		for key, value := range attrs {
+			target[key] = value
+		}
+		return nil, nil
+	}
+
+	line := p.token.line
+	from, err := p.endpoint(*scope)
+	if err != nil {
+		return nil, err
+	}
+	// A graph attribute written as key = value
+	if len(from) == 1 && p.punct("=") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.token.kind != dotID {
+			return nil, p.unexpected("a value")
+		}
+		if p.depth == 1 {
+			p.current.attrs[from[0]] = p.token.text
+		}
+		return nil, p.advance()
+	}
+
+	mentioned := from
+	var chain [][]string
+	for p.punct("->") || p.punct("--") {
+		if p.token.text == "--" {
+			return nil, fmt.Errorf("line %d: undirected edges cannot be imported, use ->", p.token.line)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		to, err := p.endpoint(*scope)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, to)
+		mentioned = append(mentioned, to...)
+	}
+
+	attrs := map[string]string{}
+	if p.punct("[") {
+		if attrs, err = p.attrList(); err != nil {
+			return nil, err
+		}
+	}
+	if len(chain) == 0 {
+		// A node statement
+		for _, node := range from {
+			for key, value := range attrs {
+				p.current.nodeAttrs[node][key] = value
+			}
+		}
+		return mentioned, nil
+	}
+	for _, to := range chain {
+		for _, a := range from {
+			for _, b := range to {
+				edge := dotEdge{from: a, to: b, attrs: cloneAttrs(scope.edge), line: line}
+				for key, value := range attrs {
+					edge.attrs[key] = value
+				}
+				p.current.edges = append(p.current.edges, edge)
+			}
+		}
+		from = to
+	}
+	return mentioned, nil
+}
+
+// Parses a node, dropping any port, or a subgraph, and returns the nodes
+// it names
+func (p *dotParser) endpoint(scope dotScope) ([]string, error) {
+	if p.token.is("subgraph") || p.punct("{") {
+		if p.token.is("subgraph") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if p.token.kind == dotID {
+				if err := p.advance(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return p.block(scope)
+	}
+	if p.token.kind != dotID {
+		return nil, p.unexpected("a node or a statement")
+	}
+	node := p.token.text
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	for p.punct(":") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := p.current.nodeAttrs[node]; !ok && !p.punct("=") {
+		p.current.nodes = append(p.current.nodes, node)
+		p.current.nodeAttrs[node] = cloneAttrs(scope.node)
+	}
+	return []string{node}, nil
+}
+
+// attr_list := ('[' (ID '=' ID [;|,])* ']')+
+func (p *dotParser) attrList() (map[string]string, error) {
+	attrs := map[string]string{}
+	for p.punct("[") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		for !p.punct("]") {
+			if p.token.kind != dotID {
+				return nil, p.unexpected("an attribute name")
+			}
+			key := strings.ToLower(p.token.text)
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			value := "true"
+			if p.punct("=") {
+				if err := p.advance(); err != nil {
+					return nil, err
+				}
+				if p.token.kind != dotID {
+					return nil, p.unexpected("an attribute value")
+				}
+				value = p.token.text
+				if p.token.html {
+					value = htmlText(value)
+				}
+				if err := p.advance(); err != nil {
+					return nil, err
+				}
+			}
+			attrs[key] = value
+			if p.punct(",") || p.punct(";") {
+				if err := p.advance(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return attrs, nil
+}
+
+func cloneAttrs(attrs map[string]string) map[string]string {
+	clone := make(map[string]string, len(attrs))
+	for key, value := range attrs {
+		clone[key] = value
+	}
+	return clone
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// Returns the text of an HTML label without its markup
+func htmlText(label string) string {
+	return html.UnescapeString(htmlTag.ReplaceAllString(label, ""))
+}
+
+// Reads "dfa name" or "nfa name" in a graph label
+var dotKindLabel = regexp.MustCompile(`^(dfa|nfa)\s+\S+$`)
+
+// Separators of plain labels, including the line breaks of DOT labels
+var dotLabelSeparator = regexp.MustCompile(`\s*(,|\n|\\[nlr])\s*`)
+
+func (g *dotGraph) automaton() (*sourceAutomaton, error) {
+	automaton := &sourceAutomaton{name: g.name}
+	if match := dotKindLabel.FindStringSubmatch(g.attrs["label"]); match != nil {
+		automaton.kind = keywords[match[1]]
+	}
+
+	// Helper nodes drawing the entry arrow are not states
+	helper := func(node string) bool {
+		attrs := g.nodeAttrs[node]
+		return attrs["shape"] == "point" || attrs["style"] == "invis"
+	}
+	initial := ""
+	for _, edge := range g.edges {
+		if helper(edge.from) && !helper(edge.to) {
+			if initial != "" && initial != edge.to {
+				return nil, fmt.Errorf("both %s and %s are marked initial", initial, edge.to)
+			}
+			initial = edge.to
+		}
+	}
+	for _, node := range g.nodes {
+		if g.nodeAttrs[node]["initial"] == "true" && initial == "" {
+			initial = node
+		}
+	}
+
+	for _, node := range g.nodes {
+		if helper(node) {
+			continue
+		}
+		if initial == "" {
+			initial = node // The first node when nothing marks one
+		}
+		attrs := g.nodeAttrs[node]
+		final := attrs["shape"] == "doublecircle" || attrs["peripheries"] == "2" || attrs["final"] == "true"
+		state := sourceState{name: node, kind: STATE}
+		switch {
+		case node == initial && final:
+			return nil, fmt.Errorf("initial state %s cannot also be final", node)
+		case node == initial:
+			state.kind = INITIAL
+		case final:
+			state.kind = FINAL
+		}
+		automaton.states = append(automaton.states, state)
+	}
+	if len(automaton.states) == 0 {
+		return nil, fmt.Errorf("no states")
+	}
+
+	index := make(map[[2]string]int)
+	for _, edge := range g.edges {
+		if helper(edge.from) || helper(edge.to) {
+			continue
+		}
+		conditions, err := dotConditions(edge.attrs["label"])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: edge %s -> %s: %w", edge.line, edge.from, edge.to, err)
+		}
+		key := [2]string{edge.from, edge.to}
+		if i, ok := index[key]; ok {
+			automaton.transitions[i].conditions = append(automaton.transitions[i].conditions, conditions...)
+			continue
+		}
+		index[key] = len(automaton.transitions)
+		automaton.transitions = append(automaton.transitions, sourceTransition{edge.from, edge.to, conditions})
+	}
+	return automaton, nil
+}
+
+// Reads the conditions of an edge label, as written in a transition or as
+// plain symbols
+func dotConditions(label string) ([]string, error) {
+	if strings.TrimSpace(label) == "" {
+		return nil, fmt.Errorf("the edge has no label")
+	}
+	if conditions, err := conditionsSource(label); err == nil {
+		return conditions, nil
+	}
+	var conditions []string
+	for _, symbol := range dotLabelSeparator.Split(strings.TrimSpace(label), -1) {
+		switch symbol {
+		case "":
+			continue
+		case "ε", "λ", "eps", "epsilon", "lambda":
+			return nil, fmt.Errorf("empty (%s) transitions are not supported", symbol)
+		}
+		condition, err := stringSource(symbol)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+	if len(conditions) == 0 {
+		return nil, fmt.Errorf("the edge has no label")
+	}
+	return conditions, nil
+}
@@ -0,0 +1,92 @@
+package stateflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDOTImportRoundTrip(t *testing.T) {
+	source := `dfa counter {
+  initial q0;
+  state q1;
+  final q2;
+
+  on q0 -> q1 when "inc";
+  on q1 -> q2 when "inc" or /[0-9]+/;
+  on q2 -> q2 when "reset";
+}
+
+nfa tags {
+  initial q0;
+  final q1;
+
+  on q0 -> q1 when "<a>" or /[0-9]+/;
+  on q0 -> q0 when "x";
+}
+`
+	dot := export(t, "dot", source)
+	imported, err := Import(strings.NewReader(dot), "dot", "machine")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if string(imported) != source {
+		t.Errorf("Expected the round trip to give back the source, got:\n%s", imported)
+	}
+}
+
+func TestDOTImportConventions(t *testing.T) {
+	dot := `// Hand-drawn
+digraph {
+	rankdir = LR
+	node [shape = doublecircle]; accept;
+	node [shape = circle];
+	"" [shape = none, style = invis];
+	"" -> q0;
+	q0 -> q1 [label = "a, b"];
+	q1 -> q1 [label = "b\nc"];
+	q1 -> accept [label = <<i>d</i>>];
+	subgraph cluster_late {
+		edge [label = "e"];
+		q0 -> { accept q1 };
+	}
+	q0 -> accept [label = "f"];
+}`
+	expected := `nfa notes {
+  initial q0;
+  final accept;
+  state q1;
+
+  on q0 -> q1     when "a" or "b" or "e";
+  on q1 -> q1     when "b" or "c";
+  on q1 -> accept when "d";
+  on q0 -> accept when "e" or "f";
+}
+`
+	imported, err := Import(strings.NewReader(dot), "dot", "notes")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if string(imported) != expected {
+		t.Errorf("Unexpected source:\n%s", imported)
+	}
+}
+
+func TestDOTImportErrors(t *testing.T) {
+	tests := []struct {
+		dot     string
+		message string
+	}{
+		{`graph g { a -- b }`, "undirected graphs"},
+		{`digraph g { a -> b [label="ε"] }`, "empty (ε) transitions"},
+		{`digraph g { s [shape=point]; s -> a; a [shape=doublecircle] }`, "cannot also be final"},
+		{`digraph g { a -> b }`, "no label"},
+		{`digraph g { a -> b [label="x"]`, "expect '}'"},
+		{`digraph g { a -> b [label="x"]; c [shape=doublecircle]; c -> a [label="y"] }`, "not valid"},
+	}
+	for _, test := range tests {
+		_, err := Import(strings.NewReader(test.dot), "dot", "machine")
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Expected an error containing %q for %s, got: %v", test.message, test.dot, err)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package stateflow
+
+import (
+	"fmt"
+	"io"
+)
+
+// Export writes the automata among the definitions in the given format
+func Export(w io.Writer, format string, defs []Definition) error {
+	graphs := automatonGraphs(defs)
+	if len(graphs) == 0 {
+		return fmt.Errorf("no automata to export")
+	}
+	switch format {
+	case "dot":
+		return exportDOT(w, graphs)
+	case "mermaid":
+		return exportMermaid(w, graphs)
+	case "plantuml":
+		return exportPlantUML(w, graphs)
+	case "scxml":
+		return exportSCXML(w, graphs)
+	case "jflap", "jff":
+		return exportJFLAP(w, graphs)
+	case "tikz":
+		return exportTikZ(w, graphs)
+	case "graphml":
+		return exportGraphML(w, graphs)
+	case "json":
+		return exportJSON(w, graphs)
+	}
+	return fmt.Errorf("unknown export format '%s'", format)
+}
@@ -0,0 +1,229 @@
+package stateflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func getDefinitions(t *testing.T, source string) []Definition {
+	parser := Parser{Tokens: getTokens(source)}
+	defs, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Expected no parse error, got: %v", err)
+	}
+	return defs
+}
+
+func export(t *testing.T, format string, source string) string {
+	var b bytes.Buffer
+	if err := Export(&b, format, getDefinitions(t, source)); err != nil {
+		t.Fatalf("Expected no error exporting %s, got: %v", format, err)
+	}
+	return b.String()
+}
+
+const parallelSource = `nfa tags {
+	initial q0;
+	final q1;
+
+	on q0 -> q1 when "<a>";
+	on q0 -> q1 when /[0-9]+/;
+	on q0 -> q0 when "x";
+}`
+
+func TestExportDOT(t *testing.T) {
+	dot := export(t, "dot", counterSource)
+
+	expected := []string{
+		`digraph "counter" {`,
+		`"__start_q0" [shape=point, label=""];`,
+		`"__start_q0" -> "q0";`,
+		`"q2" [shape=doublecircle];`,
+		`"q0" -> "q1" [label=<"inc">];`,
+		`"q1" -> "q2" [label=<"inc" or <I>/[0-9]+/</I>>];`,
+		`"q2" -> "q2" [label=<"reset">];`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(dot, line) {
+			t.Errorf("Expected %q in output:\n%s", line, dot)
+		}
+	}
+	if strings.Contains(dot, "main") {
+		t.Errorf("Expected functions to be left out:\n%s", dot)
+	}
+}
+
+func TestExportDOTMergesParallelTransitions(t *testing.T) {
+	dot := export(t, "dot", parallelSource)
+
+	if n := strings.Count(dot, `"q0" -> "q1"`); n != 1 {
+		t.Errorf("Expected a single q0 -> q1 edge, got %d:\n%s", n, dot)
+	}
+	if !strings.Contains(dot, `"q0" -> "q1" [label=<"&lt;a&gt;" or <I>/[0-9]+/</I>>];`) {
+		t.Errorf("Expected merged and escaped label:\n%s", dot)
+	}
+}
+
+func TestExportUnknownFormat(t *testing.T) {
+	var b bytes.Buffer
+	if err := Export(&b, "png", getDefinitions(t, counterSource)); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestExportMermaid(t *testing.T) {
+	mermaid := export(t, "mermaid", counterSource)
+
+	expected := "stateDiagram-v2\n" +
+		"  state q0\n  state q1\n  state q2\n" +
+		"  [*] --> q0\n" +
+		"  q0 --> q1 : \"inc\"\n" +
+		"  q1 --> q2 : \"inc\" or /[0-9]+/\n" +
+		"  q2 --> q2 : \"reset\"\n" +
+		"  q2 --> [*]\n"
+	if mermaid != expected {
+		t.Errorf("Unexpected Mermaid diagram:\n%s", mermaid)
+	}
+}
+
+func TestExportMermaidSeveralAutomata(t *testing.T) {
+	mermaid := export(t, "mermaid", counterSource+"\n"+parallelSource)
+
+	for _, line := range []string{
+		"  state counter {",
+		`    state "q0" as counter_q0`,
+		"    [*] --> tags_q0",
+		`    tags_q0 --> tags_q1 : "<a>" or /[0-9]+/`,
+		"    tags_q1 --> [*]",
+	} {
+		if !strings.Contains(mermaid, line+"\n") {
+			t.Errorf("Expected %q in output:\n%s", line, mermaid)
+		}
+	}
+}
+
+func TestExportPlantUML(t *testing.T) {
+	plantuml := export(t, "plantuml", counterSource+"\n"+parallelSource)
+
+	if n := strings.Count(plantuml, "@startuml"); n != 2 {
+		t.Errorf("Expected a diagram per automaton, got %d", n)
+	}
+	for _, line := range []string{
+		"@startuml counter",
+		"[*] --> q0",
+		"q1 --> q2 : \"inc\" or /[0-9]+/",
+		"q2 --> [*]",
+		"@enduml",
+	} {
+		if !strings.Contains(plantuml, line+"\n") {
+			t.Errorf("Expected %q in output:\n%s", line, plantuml)
+		}
+	}
+}
+
+func TestExportTikZ(t *testing.T) {
+	tikz := export(t, "tikz", cyclicSource)
+
+	for _, line := range []string{
+		`\usetikzlibrary{automata, arrows.meta}`,
+		`\begin{tikzpicture}[`,
+		`  \node[state, initial] (q0) at (0.00cm, `,
+		`  \node[state, accepting] (q3) at `,
+		`  \path (q0) edge [bend left] node {\texttt{"a"}} (q1);`,
+		`  \path (q1) edge [bend left] node {\texttt{"b"}} (q0);`,
+		`  \path (q2) edge [loop above] node {\texttt{"e"}} ();`,
+		`  \path (q2) edge node {\texttt{"g"}} (q3);`,
+		`  \draw[rounded corners] (q0) -- (`,
+		`\end{tikzpicture}`,
+	} {
+		if !strings.Contains(tikz, line) {
+			t.Errorf("Expected %q in output:\n%s", line, tikz)
+		}
+	}
+}
+
+func TestExportTikZEscapesLabels(t *testing.T) {
+	tikz := export(t, "tikz", `nfa money {
+	initial q0;
+	final q1;
+
+	on q0 -> q1 when "$_{x}" or /[0-9]+%/;
+}`)
+	expected := `\texttt{"\$\_\{x\}"} or \textit{\texttt{/[0-9]+\%/}}`
+	if !strings.Contains(tikz, expected) {
+		t.Errorf("Expected %q in output:\n%s", expected, tikz)
+	}
+}
+
+func TestExportGraphML(t *testing.T) {
+	graphml := export(t, "graphml", parallelSource)
+
+	var document struct {
+		Graphs []struct {
+			ID    string `xml:"id,attr"`
+			Nodes []struct {
+				ID string `xml:"id,attr"`
+			} `xml:"node"`
+			Edges []struct {
+				Source string `xml:"source,attr"`
+				Target string `xml:"target,attr"`
+				Data   []struct {
+					Key   string `xml:"key,attr"`
+					Value string `xml:",chardata"`
+				} `xml:"data"`
+			} `xml:"edge"`
+		} `xml:"graph"`
+	}
+	if err := xml.Unmarshal([]byte(graphml), &document); err != nil {
+		t.Fatalf("Expected well-formed GraphML, got: %v\n%s", err, graphml)
+	}
+	if len(document.Graphs) != 1 || document.Graphs[0].ID != "tags" {
+		t.Fatalf("Expected a single graph 'tags':\n%s", graphml)
+	}
+	graph := document.Graphs[0]
+	if len(graph.Nodes) != 2 || len(graph.Edges) != 3 {
+		t.Fatalf("Expected 2 nodes and 3 edges, got %d and %d", len(graph.Nodes), len(graph.Edges))
+	}
+	first := graph.Edges[0]
+	if first.Source != "tags:q0" || first.Target != "tags:q1" {
+		t.Errorf("Expected the first edge to go from tags:q0 to tags:q1, got %s to %s", first.Source, first.Target)
+	}
+	if first.Data[0].Key != "conditions" || first.Data[0].Value != `"<a>"` {
+		t.Errorf("Expected the conditions as written, got %q", first.Data[0].Value)
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	var document jsonGraph
+	if err := json.Unmarshal([]byte(export(t, "json", counterSource)), &document); err != nil {
+		t.Fatalf("Expected valid JSON, got: %v", err)
+	}
+	if document.Version != GraphVersion || len(document.Automata) != 1 {
+		t.Fatalf("Expected one automaton at version %d, got %+v", GraphVersion, document)
+	}
+	automaton := document.Automata[0]
+	if automaton.Name != "counter" || automaton.Kind != "dfa" || automaton.Initial != "q0" || automaton.Line != 1 {
+		t.Errorf("Unexpected automaton header: %+v", automaton)
+	}
+	if len(automaton.States) != 3 || automaton.States[2].Type != "final" || automaton.States[0].Line == 0 {
+		t.Errorf("Unexpected states: %+v", automaton.States)
+	}
+
+	var regex *jsonCondition
+	for _, transition := range automaton.Transitions {
+		if transition.Line == 0 || transition.Column == 0 {
+			t.Errorf("Expected a source position for %s -> %s", transition.From, transition.To)
+		}
+		for i, condition := range transition.Conditions {
+			if condition.Kind == "regex" {
+				regex = &transition.Conditions[i]
+			}
+		}
+	}
+	if regex == nil || regex.Value != "[0-9]+" || regex.Source != "/[0-9]+/" {
+		t.Errorf("Expected the regex condition with and without slashes, got %+v", regex)
+	}
+}
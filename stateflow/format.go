@@ -0,0 +1,296 @@
+package stateflow
+
+import (
+	"strings"
+)
+
+const indent = "  "
+
+// Format rewrites a source file into the canonical layout: two-space
+// indentation, one blank line between definitions, state declarations
+// grouped before transitions, `->` surrounded by spaces, explicit
+// semicolons and the `when` clauses of an automaton aligned. Comments are
+// kept next to the statement they were written on or above.
+func Format(source []byte) ([]byte, error) {
+	root, errs := ParseCST(source)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	f := formatter{}
+	first := true
+	for _, child := range root.Children {
+		switch c := child.(type) {
+		case *CSTNode:
+			if !first {
+				f.b.WriteString("\n")
+			}
+			first = false
+			var err error
+			switch c.Kind {
+			case NodeAutomatonDef:
+				err = f.automatonDef(c)
+			case NodeFunctionDef:
+				err = f.functionDef(c)
+			default:
+				err = unexpected(c.Tokens()[0])
+			}
+			if err != nil {
+				return nil, err
+			}
+		case *CSTToken:
+			if c.Token.tokenType == EOF {
+				comments := commentsOf(c.Leading)
+				if len(comments) > 0 && !first {
+					f.b.WriteString("\n")
+				}
+				f.lines("", comments)
+			}
+		}
+	}
+	return []byte(f.b.String()), nil
+}
+
+type formatter struct {
+	b strings.Builder
+}
+
+func (f *formatter) lines(prefix string, lines []string) {
+	for _, line := range lines {
+		f.b.WriteString(prefix + line + "\n")
+	}
+}
+
+// Writes a line with the given trailing comments appended
+func (f *formatter) line(prefix string, text string, comments []string) {
+	f.b.WriteString(prefix + text)
+	if len(comments) > 0 {
+		f.b.WriteString(" " + strings.Join(comments, " "))
+	}
+	f.b.WriteString("\n")
+}
+
+func commentsOf(trivia []Trivia) []string {
+	var comments []string
+	for _, t := range trivia {
+		if t.Kind == TriviaComment {
+			comments = append(comments, strings.TrimRight(t.Text, " \t\r"))
+		}
+	}
+	return comments
+}
+
+// Splits the comments around a run of tokens into the ones written on
+// their own lines before the first token and the ones written between or
+// after the tokens, which stay on the same line
+func splitComments(tokens []*CSTToken) (above []string, trailing []string) {
+	for i, token := range tokens {
+		if i == 0 {
+			above = commentsOf(token.Leading)
+		} else {
+			trailing = append(trailing, commentsOf(token.Leading)...)
+		}
+		trailing = append(trailing, commentsOf(token.Trailing)...)
+	}
+	return above, trailing
+}
+
+func unexpected(token *CSTToken) error {
+	message := "Unexpected token, cannot format."
+	if token.Token.tokenType == EOF {
+		message = "Unexpected end of file, cannot format."
+	}
+	return ParseError{&token.Token, message}
+}
+
+// Checks that the tokens have the given types, where an empty entry stands
+// for any run of conditions joined by 'or'
+func expectTokens(tokens []*CSTToken, types ...TokenType) error {
+	i := 0
+	for _, expected := range types {
+		if i >= len(tokens) {
+			return unexpected(tokens[len(tokens)-1])
+		}
+		if expected == "" {
+			for {
+				if t := tokens[i].Token.tokenType; t != STRING_LITERAL && t != REGEX {
+					return unexpected(tokens[i])
+				}
+				i++
+				if i+1 >= len(tokens) || tokens[i].Token.tokenType != OR {
+					break
+				}
+				i++
+			}
+			continue
+		}
+		if tokens[i].Token.tokenType != expected {
+			return unexpected(tokens[i])
+		}
+		i++
+	}
+	if i < len(tokens) {
+		return unexpected(tokens[i])
+	}
+	return nil
+}
+
+// Splits a definition node into its header tokens, its statements and its
+// closing brace
+func definitionParts(node *CSTNode) (header []*CSTToken, body []*CSTNode, closing *CSTToken, err error) {
+	for _, child := range node.Children {
+		switch c := child.(type) {
+		case *CSTToken:
+			if c.Token.tokenType == RIGHT_BRACE {
+				closing = c
+			} else {
+				header = append(header, c)
+			}
+		case *CSTNode:
+			if c.Kind == NodeError {
+				return nil, nil, nil, unexpected(c.Tokens()[0])
+			}
+			body = append(body, c)
+		}
+	}
+	if closing == nil {
+		last := node.Tokens()
+		return nil, nil, nil, unexpected(last[len(last)-1])
+	}
+	return header, body, closing, nil
+}
+
+// Returns the statement tokens without the terminating semicolon
+func statementTokens(node *CSTNode) ([]*CSTToken, *CSTToken) {
+	tokens := node.Tokens()
+	last := tokens[len(tokens)-1]
+	if last.Token.tokenType == SEMICOLON {
+		return tokens[:len(tokens)-1], last
+	}
+	return tokens, nil
+}
+
+func (f *formatter) automatonDef(node *CSTNode) error {
+	header, body, closing, err := definitionParts(node)
+	if err != nil {
+		return err
+	}
+	if err := expectTokens(header, header[0].Token.tokenType, IDENTIFIER, LEFT_BRACE); err != nil {
+		return err
+	}
+
+	above, trailing := splitComments(header)
+	f.lines("", above)
+	f.line("", header[0].Token.lexeme+" "+header[1].Token.lexeme+" {", trailing)
+
+	type line struct {
+		above    []string
+		head     string
+		tail     string
+		trailing []string
+	}
+	var states, transitions []line
+	width := 0
+
+	for _, stmt := range body {
+		tokens, semicolon := statementTokens(stmt)
+		if semicolon == nil {
+			return unexpected(closing)
+		}
+		if len(tokens) == 0 {
+			return unexpected(semicolon)
+		}
+		above, trailing := splitComments(append(tokens, semicolon))
+
+		if stmt.Kind == NodeStateDecl {
+			if err := expectTokens(tokens, tokens[0].Token.tokenType, IDENTIFIER); err != nil {
+				return err
+			}
+			head := tokens[0].Token.lexeme + " " + tokens[1].Token.lexeme
+			states = append(states, line{above, head, "", trailing})
+			continue
+		}
+
+		if err := expectTokens(tokens, ON, IDENTIFIER, ARROW_RIGHT, IDENTIFIER, WHEN, ""); err != nil {
+			return err
+		}
+		head := "on " + tokens[1].Token.lexeme + " -> " + tokens[3].Token.lexeme
+		width = max(width, len(head))
+		var conditions []string
+		for _, token := range tokens[5:] {
+			conditions = append(conditions, token.Token.lexeme)
+		}
+		tail := "when " + strings.Join(conditions, " ")
+		transitions = append(transitions, line{above, head, tail, trailing})
+	}
+
+	for _, state := range states {
+		f.lines(indent, state.above)
+		f.line(indent, state.head+";", state.trailing)
+	}
+	if len(states) > 0 && len(transitions) > 0 {
+		f.b.WriteString("\n")
+	}
+	for _, transition := range transitions {
+		f.lines(indent, transition.above)
+		padding := strings.Repeat(" ", width-len(transition.head))
+		f.line(indent, transition.head+padding+" "+transition.tail+";", transition.trailing)
+	}
+
+	f.lines(indent, commentsOf(closing.Leading))
+	f.line("", "}", commentsOf(closing.Trailing))
+	return nil
+}
+
+func (f *formatter) functionDef(node *CSTNode) error {
+	header, body, closing, err := definitionParts(node)
+	if err != nil {
+		return err
+	}
+	if len(header) < 4 {
+		return unexpected(header[len(header)-1])
+	}
+
+	// fn name ( [param {, param}] ) {
+	var params []string
+	for i, token := range header[3 : len(header)-2] {
+		expected := IDENTIFIER
+		if i%2 == 1 {
+			expected = COMMA
+		}
+		if token.Token.tokenType != expected {
+			return unexpected(token)
+		}
+		if expected == IDENTIFIER {
+			params = append(params, token.Token.lexeme)
+		}
+	}
+	if len(params) > 0 && header[len(header)-3].Token.tokenType == COMMA {
+		return unexpected(header[len(header)-3])
+	}
+	if err := expectTokens([]*CSTToken{header[0], header[1], header[2], header[len(header)-2], header[len(header)-1]},
+		FUNCTION, IDENTIFIER, LEFT_PAREN, RIGHT_PAREN, LEFT_BRACE); err != nil {
+		return err
+	}
+
+	above, trailing := splitComments(header)
+	f.lines("", above)
+	f.line("", "fn "+header[1].Token.lexeme+"("+strings.Join(params, ", ")+") {", trailing)
+
+	for _, statement := range body {
+		tokens, semicolon := statementTokens(statement)
+		if semicolon == nil {
+			return unexpected(closing)
+		}
+		if err := expectTokens(tokens, IDENTIFIER, ARROW_LEFT, IDENTIFIER); err != nil {
+			return err
+		}
+		above, trailing := splitComments(append(tokens, semicolon))
+		f.lines(indent, above)
+		f.line(indent, tokens[0].Token.lexeme+" <- "+tokens[2].Token.lexeme+";", trailing)
+	}
+
+	f.lines(indent, commentsOf(closing.Leading))
+	f.line("", "}", commentsOf(closing.Trailing))
+	return nil
+}
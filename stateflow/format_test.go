@@ -0,0 +1,70 @@
+package stateflow
+
+import (
+	"testing"
+)
+
+func TestFormatCanonicalLayout(t *testing.T) {
+	source := `// counter machine
+dfa   counter{
+on q0->q1 when "inc"
+	initial q0 ; // start
+on q1 -> q22 when "inc"or /[0-9]/;
+    state q1
+  final q22
+  // end of body
+}
+fn main( input,other ){counter<-input;}`
+
+	expected := `// counter machine
+dfa counter {
+  initial q0; // start
+  state q1;
+  final q22;
+
+  on q0 -> q1  when "inc";
+  on q1 -> q22 when "inc" or /[0-9]/;
+  // end of body
+}
+
+fn main(input, other) {
+  counter <- input;
+}
+`
+	formatted, err := Format([]byte(source))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if string(formatted) != expected {
+		t.Errorf("Unexpected format:\n%s", formatted)
+	}
+}
+
+func TestFormatIdempotent(t *testing.T) {
+	source := "dfa a { // header\n  // first\n  initial q0\n  on q0 -> q0 when \"a\" // loop\n}\n// trailing\n"
+	once, err := Format([]byte(source))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	twice, err := Format(once)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if string(once) != string(twice) {
+		t.Errorf("Format is not idempotent:\n%s\n---\n%s", once, twice)
+	}
+}
+
+func TestFormatRejectsSyntaxErrors(t *testing.T) {
+	sources := []string{
+		"dfa a { on q0 -> when \"a\"; }",
+		"dfa a { initial q0; ",
+		"fn main(a,) { }",
+		"dfa a { initial q0 \"x\"; }",
+	}
+	for _, source := range sources {
+		if _, err := Format([]byte(source)); err == nil {
+			t.Errorf("Expected error formatting %q", source)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package stateflow
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// generatedAutomaton is a dfa prepared for code generation: its table,
+// and sample inputs with the interpreter's verdict for the generated tests
+type generatedAutomaton struct {
+	*Automaton
+	table   *matchTable
+	samples []sample
+}
+
+type sample struct {
+	input    string
+	accepted bool
+}
+
+// generatedAutomata compiles the dfa automata among the definitions. Nfa
+// automata are left out.
+func generatedAutomata(defs []Definition) ([]*generatedAutomaton, error) {
+	var generated []*generatedAutomaton
+	for _, def := range defs {
+		automatonDef, ok := def.(*AutomatonDef)
+		if !ok || automatonDef.autType.tokenType != DFA {
+			continue
+		}
+		automaton, err := NewAutomaton(automatonDef)
+		if err != nil {
+			return nil, err
+		}
+		table, err := compileTable(automaton)
+		if err != nil {
+			return nil, err
+		}
+		g := &generatedAutomaton{Automaton: automaton, table: table}
+		for _, input := range sampleInputs(automaton) {
+			accepted := automaton.Run(input).Accepted
+			if table.match(input) != accepted {
+				return nil, fmt.Errorf("the table of '%s' disagrees with the interpreter on %q", automaton.Name, input)
+			}
+			g.samples = append(g.samples, sample{input, accepted})
+		}
+		generated = append(generated, g)
+	}
+	if len(generated) == 0 {
+		return nil, fmt.Errorf("no dfa to generate code for; nfa automata are not supported")
+	}
+	return generated, nil
+}
+
+// Returns an error naming the first nfa among the definitions, for
+// backends that would otherwise leave it out of their output
+func rejectNFA(defs []Definition, language string) error {
+	for _, def := range defs {
+		if automatonDef, ok := def.(*AutomatonDef); ok && automatonDef.autType.tokenType == NFA {
+			return fmt.Errorf("'%s' is an nfa, but %s generation only supports dfa automata", automatonDef.name.lexeme, language)
+		}
+	}
+	return nil
+}
+
+// sampleInputs returns inputs exercising every transition of an
+// automaton: the shortest way into each state, each condition taken from
+// there, and the same inputs finished off in a final state when possible
+func sampleInputs(automaton *Automaton) []string {
+	examples := make(map[*transition][]string)
+	for _, transitions := range automaton.transitions {
+		for _, t := range transitions {
+			for i, matcher := range t.conditions {
+				if matcher.pattern == nil {
+					examples[t] = append(examples[t], matcher.text)
+				} else if example, ok := regexExample(t.decl.conditions[i].(RegexCondition).pattern); ok {
+					examples[t] = append(examples[t], example)
+				}
+			}
+		}
+	}
+
+	// Shortest inputs leading into each state
+	prefix := map[string]string{automaton.Initial: ""}
+	queue := []string{automaton.Initial}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for _, t := range automaton.transitions[state] {
+			for _, example := range examples[t] {
+				if _, ok := prefix[t.decl.toState.lexeme]; !ok {
+					prefix[t.decl.toState.lexeme] = prefix[state] + example
+					queue = append(queue, t.decl.toState.lexeme)
+				}
+			}
+		}
+	}
+
+	// Inputs leading from each state into a final state
+	suffix := make(map[string]string)
+	for changed := true; changed; {
+		changed = false
+		for _, state := range automaton.States {
+			name := state.name.lexeme
+			if _, ok := suffix[name]; ok {
+				continue
+			}
+			if automaton.IsFinal(name) {
+				suffix[name], changed = "", true
+				continue
+			}
+			for _, t := range automaton.transitions[name] {
+				if rest, ok := suffix[t.decl.toState.lexeme]; ok && len(examples[t]) > 0 {
+					suffix[name], changed = examples[t][0]+rest, true
+					break
+				}
+			}
+		}
+	}
+
+	var inputs []string
+	seen := make(map[string]bool)
+	add := func(input string) {
+		if !seen[input] {
+			seen[input] = true
+			inputs = append(inputs, input)
+		}
+	}
+	add("")
+	for _, state := range automaton.States {
+		name := state.name.lexeme
+		start, ok := prefix[name]
+		if !ok {
+			continue
+		}
+		add(start)
+		for _, t := range automaton.transitions[name] {
+			for _, example := range examples[t] {
+				add(start + example)
+				if rest, ok := suffix[t.decl.toState.lexeme]; ok {
+					add(start + example + rest)
+					add(start + example + rest + "\xff")
+				}
+			}
+		}
+	}
+	return inputs
+}
+
+// regexExample returns a short input the regex matches, preferring
+// printable characters
+func regexExample(pattern string) (string, bool) {
+	re, err := syntax.Parse(strings.Trim(pattern, "/"), syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+	var b strings.Builder
+	var write func(re *syntax.Regexp) bool
+	write = func(re *syntax.Regexp) bool {
+		switch re.Op {
+		case syntax.OpNoMatch:
+			return false
+		case syntax.OpLiteral:
+			b.WriteString(string(re.Rune))
+		case syntax.OpCharClass:
+			if len(re.Rune) == 0 {
+				return false
+			}
+			r := re.Rune[0]
+			for i := 0; i+1 < len(re.Rune); i += 2 {
+				if lo := max(re.Rune[i], '!'); lo <= min(re.Rune[i+1], '~') {
+					r = lo
+					break
+				}
+			}
+			b.WriteRune(r)
+		case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+			b.WriteByte('a')
+		case syntax.OpCapture, syntax.OpStar, syntax.OpPlus, syntax.OpQuest:
+			// One repetition gives more useful inputs than none
+			return write(re.Sub[0])
+		case syntax.OpRepeat:
+			for i := 0; i < max(re.Min, 1); i++ {
+				if !write(re.Sub[0]) {
+					return false
+				}
+			}
+		case syntax.OpConcat:
+			for _, sub := range re.Sub {
+				if !write(sub) {
+					return false
+				}
+			}
+		case syntax.OpAlternate:
+			return write(re.Sub[0])
+		}
+		return true
+	}
+	if !write(re) || b.Len() == 0 {
+		return "", false
+	}
+	return b.String(), true
+}
+
+// writeNumbers writes the elements of a table 16 to a line, each line
+// indented and every element followed by a comma
+func writeNumbers(b *strings.Builder, indent string, numbers []int) {
+	for i, n := range numbers {
+		if i%16 == 0 {
+			b.WriteString(indent)
+		}
+		fmt.Fprintf(b, "%d,", n)
+		if i%16 == 15 || i == len(numbers)-1 {
+			b.WriteString("\n")
+		} else {
+			b.WriteString(" ")
+		}
+	}
+}
+
+// exportedName turns a name into an exported Go identifier, so order_flow
+// becomes OrderFlow. It returns "" when the name has no letters or digits.
+func exportedName(name string) string {
+	var b strings.Builder
+	words := strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	for _, word := range words {
+		r, size := utf8.DecodeRuneInString(word)
+		b.WriteRune(unicode.ToUpper(r))
+		b.WriteString(word[size:])
+	}
+	identifier := b.String()
+	if first, _ := utf8.DecodeRuneInString(identifier); identifier != "" && !unicode.IsUpper(first) {
+		identifier = "X" + identifier
+	}
+	return identifier
+}
@@ -0,0 +1,179 @@
+package stateflow
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const cHeader = "/* Code generated by \"stateflow gen c\"; DO NOT EDIT. */\n"
+
+// GenerateC writes a C header and source with a table-driven matcher for
+// every dfa among the definitions. The tables are constant and nothing is
+// allocated, so the code fits firmware. The source includes the header
+// by the given name.
+func GenerateC(header, source io.Writer, headerName string, defs []Definition) error {
+	automata, err := generatedAutomata(defs)
+	if err != nil {
+		return err
+	}
+
+	guard := cGuard(headerName)
+	var h strings.Builder
+	h.WriteString(cHeader)
+	fmt.Fprintf(&h, "#ifndef %s\n#define %s\n\n#include <stdbool.h>\n#include <stddef.h>\n#include <stdint.h>\n", guard, guard)
+	h.WriteString("\n#ifdef __cplusplus\nextern \"C\" {\n#endif\n")
+	for _, automaton := range automata {
+		name, table := automaton.Name, automaton.table
+		fmt.Fprintf(&h, `
+/* States of the dfa %[1]s, starting at %[2]s_START. State 0 rejects
+ * every input, so a scan can stop as soon as it is reached. */
+typedef %[3]s %[1]s_state;
+#define %[2]s_START %[4]d
+
+/* Returns the state after reading a byte */
+%[1]s_state %[1]s_step(%[1]s_state state, unsigned char byte);
+
+/* Reports whether an input ending in the state is accepted */
+bool %[1]s_accepts(%[1]s_state state);
+
+/* Reports whether the dfa %[1]s accepts the input */
+bool %[1]s_match(const char *input, size_t length);
+`, name, strings.ToUpper(name), cStateType(table), table.start)
+	}
+	h.WriteString("\n#ifdef __cplusplus\n}\n#endif\n\n#endif\n")
+
+	var c strings.Builder
+	c.WriteString(cHeader)
+	fmt.Fprintf(&c, "#include \"%s\"\n", headerName)
+	for _, automaton := range automata {
+		name, table := automaton.Name, automaton.table
+		fmt.Fprintf(&c, "\nstatic const bool %s_accepting[%d] = {", name, len(table.accept))
+		for state, accept := range table.accept {
+			if state > 0 {
+				c.WriteString(", ")
+			}
+			fmt.Fprint(&c, accept)
+		}
+		fmt.Fprintf(&c, "};\n\n/* Class of every byte, the bytes of a class lead to the same states */\n")
+		fmt.Fprintf(&c, "static const uint8_t %s_classes[256] = {\n", name)
+		writeNumbers(&c, "\t\t", table.classes[:])
+		fmt.Fprintf(&c, "};\n\nstatic const %s %s_next[%d][%d] = {\n", cStateType(table), name, len(table.accept), table.width)
+		for state := range table.accept {
+			fmt.Fprintf(&c, "\t/* %d */\n\t{\n", state)
+			writeNumbers(&c, "\t\t", table.row(state))
+			c.WriteString("\t},\n")
+		}
+		fmt.Fprintf(&c, `};
+
+%[1]s_state %[1]s_step(%[1]s_state state, unsigned char byte)
+{
+	return %[1]s_next[state][%[1]s_classes[byte]];
+}
+
+bool %[1]s_accepts(%[1]s_state state)
+{
+	return %[1]s_accepting[state];
+}
+
+bool %[1]s_match(const char *input, size_t length)
+{
+	%[1]s_state state = %[2]s_START;
+	for (size_t i = 0; i < length; i++) {
+		state = %[1]s_next[state][%[1]s_classes[(unsigned char)input[i]]];
+		if (state == 0)
+			return false;
+	}
+	return %[1]s_accepting[state];
+}
+`, name, strings.ToUpper(name))
+	}
+
+	if _, err := io.WriteString(header, h.String()); err != nil {
+		return err
+	}
+	_, err = io.WriteString(source, c.String())
+	return err
+}
+
+// GenerateCTests writes a program that replays inputs covering every
+// transition against the code of GenerateC and exits with 1 when any
+// verdict differs from the interpreter's
+func GenerateCTests(w io.Writer, headerName string, defs []Definition) error {
+	automata, err := generatedAutomata(defs)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString(cHeader)
+	fmt.Fprintf(&b, "#include <stdio.h>\n\n#include \"%s\"\n\n", headerName)
+	b.WriteString("struct sample {\n\tconst char *input;\n\tsize_t length;\n\tbool accepted;\n};\n\n")
+	b.WriteString("static int replay(const char *name, bool (*match)(const char *, size_t),\n")
+	b.WriteString("\t\t  const struct sample *samples, size_t count)\n{\n\tint failures = 0;\n")
+	b.WriteString("\tfor (size_t i = 0; i < count; i++) {\n")
+	b.WriteString("\t\tif (match(samples[i].input, samples[i].length) != samples[i].accepted) {\n")
+	b.WriteString("\t\t\tprintf(\"%s: expected sample %zu to be %s\\n\", name, i,\n")
+	b.WriteString("\t\t\t       samples[i].accepted ? \"accepted\" : \"rejected\");\n\t\t\tfailures++;\n\t\t}\n\t}\n")
+	b.WriteString("\treturn failures;\n}\n")
+	for _, automaton := range automata {
+		fmt.Fprintf(&b, "\nstatic const struct sample %s_samples[] = {\n", automaton.Name)
+		for _, sample := range automaton.samples {
+			fmt.Fprintf(&b, "\t{%s, %d, %t},\n", cString(sample.input), len(sample.input), sample.accepted)
+		}
+		b.WriteString("};\n")
+	}
+
+	b.WriteString("\nint main(void)\n{\n\tint failures = 0;\n")
+	for _, automaton := range automata {
+		fmt.Fprintf(&b, "\tfailures += replay(\"%[1]s\", %[1]s_match, %[1]s_samples,\n\t\t\t   sizeof %[1]s_samples / sizeof *%[1]s_samples);\n",
+			automaton.Name)
+	}
+	b.WriteString("\tif (failures > 0) {\n\t\tprintf(\"%d samples failed\\n\", failures);\n\t\treturn 1;\n\t}\n")
+	b.WriteString("\tprintf(\"all samples passed\\n\");\n\treturn 0;\n}\n")
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// Returns the smallest unsigned type holding every state of the table
+func cStateType(table *matchTable) string {
+	if len(table.accept) <= 256 {
+		return "uint8_t"
+	}
+	return "uint16_t"
+}
+
+// Returns the include guard of a header, so counter.h becomes COUNTER_H
+func cGuard(headerName string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(headerName) {
+		if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return strings.TrimLeft(b.String(), "_0123456789")
+}
+
+// Quotes a string as a C literal. Bytes outside printable ASCII are octal
+// escapes, which unlike hex escapes cannot swallow the next character.
+func cString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '?':
+			b.WriteString(`\?`) // Avoids trigraphs
+		case c >= ' ' && c <= '~':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "\\%03o", c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
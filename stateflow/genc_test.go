@@ -0,0 +1,41 @@
+package stateflow
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGenerateC(t *testing.T) {
+	defs := getDefinitions(t, counterSource)
+	var header, source, tests bytes.Buffer
+	if err := GenerateC(&header, &source, "counter.h", defs); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := GenerateCTests(&tests, "counter.h", defs); err != nil {
+		t.Fatalf("Expected no error generating tests, got: %v", err)
+	}
+
+	for _, check := range []struct{ output, expected string }{
+		{header.String(), "#ifndef COUNTER_H\n#define COUNTER_H\n"},
+		{header.String(), "typedef uint8_t counter_state;\n#define COUNTER_START 1\n"},
+		{header.String(), "bool counter_match(const char *input, size_t length);"},
+		{source.String(), "#include \"counter.h\"\n"},
+		{source.String(), "static const uint8_t counter_next["},
+		{tests.String(), `{"incinc", 6, true},`},
+		{tests.String(), `{"inc0\377", 5, false},`},
+	} {
+		if !strings.Contains(check.output, check.expected) {
+			t.Errorf("Expected %q in output:\n%s", check.expected, check.output)
+		}
+	}
+	if strings.Contains(source.String(), "malloc") {
+		t.Error("Expected no dynamic allocation")
+	}
+}
+
+func TestCString(t *testing.T) {
+	if got := cString("a\"\\?\n\x001"); got != `"a\"\\\?\012\0001"` {
+		t.Errorf("Unexpected C literal %s", got)
+	}
+}
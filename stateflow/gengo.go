@@ -0,0 +1,149 @@
+package stateflow
+
+import (
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const goHeader = "// Code generated by \"stateflow gen go\"; DO NOT EDIT.\n\n"
+
+// The matcher type of each automaton, named after it so that files
+// generated from several specifications can share a package
+const goMatcher = `
+// %[1]sMatcher is the dfa %[2]s compiled into a table indexed by state
+// and byte class
+type %[1]sMatcher struct {
+	start   uint16
+	accept  []bool
+	classes [256]uint8 // Class of every byte, the bytes of a class lead to the same states
+	width   int        // Number of classes
+	next    []uint16   // next[state*width+class], state 0 rejects every input
+}
+
+// Match reports whether the automaton accepts the input
+func (m *%[1]sMatcher) Match(input string) bool {
+	state := m.start
+	for i := 0; i < len(input); i++ {
+		state = m.next[int(state)*m.width+int(m.classes[input[i]])]
+		if state == 0 {
+			return false
+		}
+	}
+	return m.accept[state]
+}
+
+// MatchBytes reports whether the automaton accepts the input
+func (m *%[1]sMatcher) MatchBytes(input []byte) bool {
+	state := m.start
+	for _, b := range input {
+		state = m.next[int(state)*m.width+int(m.classes[b])]
+		if state == 0 {
+			return false
+		}
+	}
+	return m.accept[state]
+}
+`
+
+// GenerateGo writes a Go file with a table-driven matcher for every dfa
+// among the definitions: a variable named after the automaton, of a
+// matcher type named after it as well. The file only depends on the
+// standard library.
+func GenerateGo(w io.Writer, pkg string, defs []Definition) error {
+	automata, names, err := goAutomata(pkg, defs)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString(goHeader)
+	fmt.Fprintf(&b, "package %s\n", pkg)
+	for i, automaton := range automata {
+		table := automaton.table
+		fmt.Fprintf(&b, goMatcher, names[i], automaton.Name)
+		fmt.Fprintf(&b, "\n// %s matches the inputs accepted by the dfa %s\n", names[i], automaton.Name)
+		fmt.Fprintf(&b, "var %[1]s = &%[1]sMatcher{\n\tstart: %[2]d,\n\taccept: []bool{", names[i], table.start)
+		for state, accept := range table.accept {
+			if state > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(strconv.FormatBool(accept))
+		}
+		b.WriteString("},\n\tclasses: [256]uint8{\n")
+		writeNumbers(&b, "\t\t", table.classes[:])
+		fmt.Fprintf(&b, "\t},\n\twidth: %d,\n\tnext: []uint16{\n", table.width)
+		for state := range table.accept {
+			fmt.Fprintf(&b, "\t\t// %d\n", state)
+			writeNumbers(&b, "\t\t", table.row(state))
+		}
+		b.WriteString("\t},\n}\n")
+	}
+	return writeGoSource(w, b.String())
+}
+
+// GenerateGoTests writes tests for the file written by GenerateGo, with
+// inputs covering every transition and the interpreter's verdict on them
+func GenerateGoTests(w io.Writer, pkg string, defs []Definition) error {
+	automata, names, err := goAutomata(pkg, defs)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString(goHeader)
+	fmt.Fprintf(&b, "package %s\n\nimport \"testing\"\n", pkg)
+	for i, automaton := range automata {
+		fmt.Fprintf(&b, "\nfunc Test%s(t *testing.T) {\n\ttests := []struct {\n\t\tinput    string\n\t\taccepted bool\n\t}{\n", names[i])
+		for _, sample := range automaton.samples {
+			fmt.Fprintf(&b, "\t\t{%s, %t},\n", strconv.Quote(sample.input), sample.accepted)
+		}
+		b.WriteString("\t}\n\tfor _, test := range tests {\n")
+		for _, method := range []string{"Match(test.input)", "MatchBytes([]byte(test.input))"} {
+			fmt.Fprintf(&b, "\t\tif got := %s.%s; got != test.accepted {\n", names[i], method)
+			fmt.Fprintf(&b, "\t\t\tt.Errorf(\"%s.%s(%%q) = %%v, want %%v\", test.input, got, test.accepted)\n\t\t}\n",
+				names[i], method[:strings.Index(method, "(")])
+		}
+		b.WriteString("\t}\n}\n")
+	}
+	return writeGoSource(w, b.String())
+}
+
+// Compiles the automata and picks the names of their variables
+func goAutomata(pkg string, defs []Definition) ([]*generatedAutomaton, []string, error) {
+	if !token.IsIdentifier(pkg) {
+		return nil, nil, fmt.Errorf("'%s' is not a valid Go package name", pkg)
+	}
+	automata, err := generatedAutomata(defs)
+	if err != nil {
+		return nil, nil, err
+	}
+	var names []string
+	taken := make(map[string]string)
+	for _, automaton := range automata {
+		name := exportedName(automaton.Name)
+		if name == "" {
+			return nil, nil, fmt.Errorf("'%s' has no letters or digits to name it after in Go", automaton.Name)
+		}
+		for _, declared := range []string{name, name + "Matcher"} {
+			if other, ok := taken[declared]; ok {
+				return nil, nil, fmt.Errorf("'%s' and %s would both declare %s in Go", automaton.Name, other, declared)
+			}
+			taken[declared] = "'" + automaton.Name + "'"
+		}
+		names = append(names, name)
+	}
+	return automata, names, nil
+}
+
+func writeGoSource(w io.Writer, source string) error {
+	formatted, err := format.Source([]byte(source))
+	if err != nil {
+		return fmt.Errorf("formatting generated code: %w", err)
+	}
+	_, err = w.Write(formatted)
+	return err
+}
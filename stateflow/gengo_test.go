@@ -0,0 +1,135 @@
+package stateflow
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+func TestGenerateGo(t *testing.T) {
+	defs := getDefinitions(t, counterSource)
+	var code, tests bytes.Buffer
+	if err := GenerateGo(&code, "validators", defs); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := GenerateGoTests(&tests, "validators", defs); err != nil {
+		t.Fatalf("Expected no error generating tests, got: %v", err)
+	}
+
+	for name, source := range map[string]string{"code": code.String(), "tests": tests.String()} {
+		if _, err := parser.ParseFile(token.NewFileSet(), name+".go", source, 0); err != nil {
+			t.Errorf("Expected the %s to parse, got: %v\n%s", name, err, source)
+		}
+		if !strings.HasPrefix(source, "// Code generated by \"stateflow gen go\"; DO NOT EDIT.\n") {
+			t.Errorf("Expected the generated header in the %s", name)
+		}
+	}
+	for _, expected := range []string{"package validators", "var Counter = &CounterMatcher{", "func (m *CounterMatcher) MatchBytes("} {
+		if !strings.Contains(code.String(), expected) {
+			t.Errorf("Expected %q in the code", expected)
+		}
+	}
+	for _, expected := range []string{"func TestCounter(", `{"incinc", true},`, `{"inc", false},`} {
+		if !strings.Contains(tests.String(), expected) {
+			t.Errorf("Expected %q in the tests:\n%s", expected, tests.String())
+		}
+	}
+}
+
+func TestGenerateGoFilesShareAPackage(t *testing.T) {
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for i, source := range []string{counterSource, ordersSource} {
+		var code bytes.Buffer
+		if err := GenerateGo(&code, "validators", getDefinitions(t, source)); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		file, err := parser.ParseFile(fset, fmt.Sprintf("spec%d.go", i), code.String(), 0)
+		if err != nil {
+			t.Fatalf("Expected the code to parse, got: %v", err)
+		}
+		files = append(files, file)
+	}
+
+	config := types.Config{Importer: importer.Default()}
+	if _, err := config.Check("validators", fset, files, nil); err != nil {
+		t.Errorf("Expected the generated files to compile together, got: %v", err)
+	}
+}
+
+func TestGenerateGoErrors(t *testing.T) {
+	var b bytes.Buffer
+	if err := GenerateGo(&b, "func", getDefinitions(t, counterSource)); err == nil {
+		t.Error("Expected an error for a keyword as package name")
+	}
+	if err := GenerateGo(&b, "tags", getDefinitions(t, parallelSource)); err == nil {
+		t.Error("Expected an error when there is no dfa")
+	}
+	clash := `dfa order_flow { initial q0; final q1; on q0 -> q1 when "a"; }
+dfa orderFlow { initial q0; final q1; on q0 -> q1 when "a"; }`
+	if err := GenerateGo(&b, "orders", getDefinitions(t, clash)); err == nil {
+		t.Error("Expected an error for automata with the same Go name")
+	}
+}
+
+const ordersSource = `dfa order_flow {
+	initial pending;
+	state paid;
+	final delivered;
+
+	on pending -> paid when "pay";
+	on paid -> delivered when "deliver";
+	on paid -> pending when "refund";
+}`
+
+func TestGenerateGoWorkflow(t *testing.T) {
+	defs := getDefinitions(t, ordersSource)
+	var code, tests bytes.Buffer
+	if err := GenerateGoWorkflow(&code, "orders", defs); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := GenerateGoWorkflowTests(&tests, "orders", defs); err != nil {
+		t.Fatalf("Expected no error generating tests, got: %v", err)
+	}
+	for name, source := range map[string]string{"code": code.String(), "tests": tests.String()} {
+		if _, err := parser.ParseFile(token.NewFileSet(), name+".go", source, 0); err != nil {
+			t.Errorf("Expected the %s to parse, got: %v\n%s", name, err, source)
+		}
+	}
+
+	expected := []string{
+		"OrderFlowPending OrderFlowState = iota",
+		"OrderFlowPay OrderFlowEvent = iota",
+		`var orderFlowEventNames = [...]string{"pay", "deliver", "refund"}`,
+		"OrderFlowPaid:    {OrderFlowDeliver: OrderFlowDelivered, OrderFlowRefund: OrderFlowPending},",
+		"func (m *OrderFlow) Fire(event OrderFlowEvent) error {",
+		"func (m *OrderFlow) OnEnter(state OrderFlowState, hook OrderFlowHook) {",
+		"case OrderFlowDelivered:",
+	}
+	for _, line := range expected {
+		if !strings.Contains(code.String(), line) {
+			t.Errorf("Expected %q in the code:\n%s", line, code.String())
+		}
+	}
+	if !strings.Contains(tests.String(), "{[]OrderFlowEvent{OrderFlowPay, OrderFlowDeliver}, OrderFlowDelivered},") {
+		t.Errorf("Expected a path through every transition:\n%s", tests.String())
+	}
+}
+
+func TestGenerateGoWorkflowErrors(t *testing.T) {
+	var b bytes.Buffer
+	if err := GenerateGoWorkflow(&b, "counter", getDefinitions(t, counterSource)); err == nil ||
+		!strings.Contains(err.Error(), "/[0-9]+/") {
+		t.Errorf("Expected an error naming the regex condition, got: %v", err)
+	}
+	clash := `dfa flow { initial q0; final event; on q0 -> event when "go"; }`
+	if err := GenerateGoWorkflow(&b, "flows", getDefinitions(t, clash)); err == nil {
+		t.Error("Expected an error for a state named like the Event type")
+	}
+}
@@ -0,0 +1,282 @@
+package stateflow
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const pythonHeader = "# Code generated by \"stateflow gen python\"; DO NOT EDIT.\n"
+
+const pythonRuntime = `
+from __future__ import annotations
+
+from enum import Enum
+
+
+def _run(
+    next_: tuple[int, ...],
+    classes: tuple[int, ...],
+    width: int,
+    accepting: tuple[bool, ...],
+    start: int,
+    data: str | bytes,
+) -> bool:
+    """Runs a table indexed by state * width + class over the UTF-8 bytes of data.
+
+    Bytes of the same class lead to the same states. State 0 rejects every input.
+    """
+    if isinstance(data, str):
+        data = data.encode()
+    state = start
+    for byte in data:
+        state = next_[state * width + classes[byte]]
+        if not state:
+            return False
+    return accepting[state]
+`
+
+var pythonIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var pythonKeywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true, "assert": true, "async": true,
+	"await": true, "break": true, "class": true, "continue": true, "def": true, "del": true, "elif": true,
+	"else": true, "except": true, "finally": true, "for": true, "from": true, "global": true, "if": true,
+	"import": true, "in": true, "is": true, "lambda": true, "nonlocal": true, "not": true, "or": true,
+	"pass": true, "raise": true, "return": true, "try": true, "while": true, "with": true, "yield": true,
+}
+
+// A pythonAutomaton is a dfa with the Python names of its enum and members
+type pythonAutomaton struct {
+	*generatedAutomaton
+	enum     string
+	constant string             // Prefix of the module constants
+	members  map[string]string  // Enum member of each state
+	steps    []pythonTransition // Transitions on string conditions
+}
+
+type pythonTransition struct {
+	from, event, to string
+}
+
+// GeneratePython writes a Python module with, for every automaton among
+// the definitions, an Enum of its states, a dictionary of the transitions
+// on string conditions, and accepts and step functions prefixed with the
+// automaton's name. Every automaton must be a dfa.
+func GeneratePython(w io.Writer, defs []Definition) error {
+	automata, err := pythonAutomata(defs)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString(pythonHeader)
+	b.WriteString(pythonRuntime)
+	for _, automaton := range automata {
+		name, enum, constant, table := automaton.Name, automaton.enum, automaton.constant, automaton.table
+		fmt.Fprintf(&b, "\n\nclass %s(str, Enum):\n    \"\"\"States of the dfa %s\"\"\"\n\n", enum, name)
+		var finals []string
+		for _, state := range automaton.States {
+			member := automaton.members[state.name.lexeme]
+			fmt.Fprintf(&b, "    %s = %s\n", member, strconv.Quote(state.name.lexeme))
+			if automaton.IsFinal(state.name.lexeme) {
+				finals = append(finals, enum+"."+member)
+			}
+		}
+
+		fmt.Fprintf(&b, "\n\n%s_INITIAL = %s.%s\n", constant, enum, automaton.members[automaton.Initial])
+		if len(finals) == 0 {
+			fmt.Fprintf(&b, "%s_FINALS: frozenset[%s] = frozenset()\n", constant, enum)
+		} else {
+			fmt.Fprintf(&b, "%s_FINALS = frozenset({%s})\n", constant, strings.Join(finals, ", "))
+		}
+
+		fmt.Fprintf(&b, "\n# Transitions on string conditions, by state and event\n")
+		fmt.Fprintf(&b, "%s_TRANSITIONS: dict[%s, dict[str, %s]] = {\n", constant, enum, enum)
+		for _, state := range automaton.States {
+			var entries []string
+			for _, t := range automaton.steps {
+				if t.from == state.name.lexeme {
+					entries = append(entries, fmt.Sprintf("%s: %s.%s", strconv.Quote(t.event), enum, automaton.members[t.to]))
+				}
+			}
+			if len(entries) > 0 {
+				fmt.Fprintf(&b, "    %s.%s: {%s},\n", enum, automaton.members[state.name.lexeme], strings.Join(entries, ", "))
+			}
+		}
+		b.WriteString("}\n")
+
+		fmt.Fprintf(&b, "\n_%s_START = %d\n", constant, table.start)
+		var accepting []string
+		for _, accept := range table.accept {
+			accepting = append(accepting, pythonBool(accept))
+		}
+		fmt.Fprintf(&b, "_%s_ACCEPTING = (%s)\n", constant, strings.Join(accepting, ", "))
+		fmt.Fprintf(&b, "_%s_CLASSES = (\n", constant)
+		writeNumbers(&b, "    ", table.classes[:])
+		fmt.Fprintf(&b, ")\n_%s_WIDTH = %d\n_%s_NEXT = (\n", constant, table.width, constant)
+		for state := range table.accept {
+			fmt.Fprintf(&b, "    # %d\n", state)
+			writeNumbers(&b, "    ", table.row(state))
+		}
+		b.WriteString(")\n")
+
+		fmt.Fprintf(&b, `
+
+def %[1]s_accepts(data: str | bytes) -> bool:
+    """Reports whether the dfa %[1]s accepts the input.
+
+    Conditions match like in the stateflow interpreter. Strings are read as UTF-8.
+    """
+    return _run(_%[2]s_NEXT, _%[2]s_CLASSES, _%[2]s_WIDTH, _%[2]s_ACCEPTING, _%[2]s_START, data)
+
+
+def %[1]s_step(state: %[3]s, event: str) -> %[3]s | None:
+    """Returns the state the event leads to, or None when the state has no transition on it."""
+    return %[2]s_TRANSITIONS.get(state, {}).get(event)
+`, name, constant, enum)
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// GeneratePythonTests writes pytest cases for the module written by
+// GeneratePython, imported by the given name, with inputs covering every
+// transition and the interpreter's verdict on them
+func GeneratePythonTests(w io.Writer, module string, defs []Definition) error {
+	if !pythonIdentifier.MatchString(module) || pythonKeywords[module] {
+		return fmt.Errorf("'%s' is not a valid Python module name", module)
+	}
+	automata, err := pythonAutomata(defs)
+	if err != nil {
+		return err
+	}
+
+	var imports []string
+	for _, automaton := range automata {
+		if len(automaton.steps) > 0 {
+			imports = append(imports, automaton.enum)
+		}
+		imports = append(imports, automaton.Name+"_accepts")
+		if len(automaton.steps) > 0 {
+			imports = append(imports, automaton.Name+"_step")
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(pythonHeader)
+	fmt.Fprintf(&b, "\nimport pytest\n\nfrom %s import (\n", module)
+	for _, name := range imports {
+		fmt.Fprintf(&b, "    %s,\n", name)
+	}
+	b.WriteString(")\n")
+	for _, automaton := range automata {
+		fmt.Fprintf(&b, "\n\n@pytest.mark.parametrize(\n    (\"data\", \"accepted\"),\n    [\n")
+		for _, sample := range automaton.samples {
+			fmt.Fprintf(&b, "        (%s, %s),\n", pythonBytes(sample.input), pythonBool(sample.accepted))
+		}
+		b.WriteString("    ],\n)\n")
+		fmt.Fprintf(&b, "def test_%[1]s_accepts(data: bytes, accepted: bool) -> None:\n    assert %[1]s_accepts(data) is accepted\n", automaton.Name)
+
+		if len(automaton.steps) > 0 {
+			fmt.Fprintf(&b, "\n\ndef test_%s_step() -> None:\n", automaton.Name)
+			for _, t := range automaton.steps {
+				fmt.Fprintf(&b, "    assert %s_step(%s.%s, %s) is %s.%s\n", automaton.Name, automaton.enum,
+					automaton.members[t.from], strconv.Quote(t.event), automaton.enum, automaton.members[t.to])
+			}
+		}
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// Compiles the automata and picks the Python names of their enums,
+// members and constants
+func pythonAutomata(defs []Definition) ([]*pythonAutomaton, error) {
+	if err := rejectNFA(defs, "Python"); err != nil {
+		return nil, err
+	}
+	generated, err := generatedAutomata(defs)
+	if err != nil {
+		return nil, err
+	}
+	var automata []*pythonAutomaton
+	taken := make(map[string]string)
+	for _, g := range generated {
+		enum := exportedName(g.Name)
+		if enum == "" {
+			return nil, fmt.Errorf("'%s' has no letters or digits to name its enum after", g.Name)
+		}
+		enum += "State"
+		automaton := &pythonAutomaton{
+			generatedAutomaton: g,
+			enum:               enum,
+			constant:           strings.ToUpper(g.Name),
+			members:            make(map[string]string),
+		}
+		for _, name := range []string{enum, automaton.constant} {
+			if other, ok := taken[name]; ok {
+				return nil, fmt.Errorf("'%s' and '%s' would both be named %s in Python", g.Name, other, name)
+			}
+			taken[name] = g.Name
+		}
+
+		// Members are upper case, which keeps them clear of keywords and
+		// of names Enum reserves, such as mro
+		states := make(map[string]string)
+		for _, state := range g.States {
+			member := strings.ToUpper(state.name.lexeme)
+			if strings.HasPrefix(member, "_") {
+				member = "S" + member
+			}
+			if other, ok := states[member]; ok {
+				return nil, fmt.Errorf("states '%s' and '%s' of '%s' would both be named %s in Python",
+					state.name.lexeme, other, g.Name, member)
+			}
+			states[member] = state.name.lexeme
+			automaton.members[state.name.lexeme] = member
+		}
+
+		for _, state := range g.States {
+			for _, t := range g.transitions[state.name.lexeme] {
+				for _, condition := range t.decl.conditions {
+					if str, ok := condition.(StringCondition); ok {
+						automaton.steps = append(automaton.steps,
+							pythonTransition{t.decl.fromState.lexeme, unquote(str.value), t.decl.toState.lexeme})
+					}
+				}
+			}
+		}
+		automata = append(automata, automaton)
+	}
+	return automata, nil
+}
+
+func pythonBool(b bool) string {
+	if b {
+		return "True"
+	}
+	return "False"
+}
+
+// Quotes a string as a Python bytes literal, escaping every byte outside
+// printable ASCII
+func pythonBytes(s string) string {
+	var b strings.Builder
+	b.WriteString(`b"`)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c >= ' ' && c <= '~':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "\\x%02x", c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,58 @@
+package stateflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGeneratePython(t *testing.T) {
+	defs := getDefinitions(t, counterSource)
+	var module, tests strings.Builder
+	if err := GeneratePython(&module, defs); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := GeneratePythonTests(&tests, "counter", defs); err != nil {
+		t.Fatalf("Expected no error generating tests, got: %v", err)
+	}
+
+	for _, check := range []struct{ output, expected string }{
+		{module.String(), "class CounterState(str, Enum):\n    \"\"\"States of the dfa counter\"\"\"\n\n    Q0 = \"q0\"\n"},
+		{module.String(), "COUNTER_FINALS = frozenset({CounterState.Q2})\n"},
+		{module.String(), "    CounterState.Q1: {\"inc\": CounterState.Q2},\n"},
+		{module.String(), "def counter_accepts(data: str | bytes) -> bool:"},
+		{tests.String(), "from counter import (\n"},
+		{tests.String(), "        (b\"inc0\\xff\", False),\n"},
+		{tests.String(), "    assert counter_step(CounterState.Q2, \"reset\") is CounterState.Q2\n"},
+	} {
+		if !strings.Contains(check.output, check.expected) {
+			t.Errorf("Expected %q in output:\n%s", check.expected, check.output)
+		}
+	}
+}
+
+func TestGeneratePythonErrors(t *testing.T) {
+	defs := getDefinitions(t, `dfa cases {
+	initial q;
+	final Q;
+
+	on q -> Q when "up";
+}`)
+	if err := GeneratePython(&strings.Builder{}, defs); err == nil || !strings.Contains(err.Error(), "both be named Q") {
+		t.Errorf("Expected a member clash error, got: %v", err)
+	}
+	if err := GeneratePythonTests(&strings.Builder{}, "import", getDefinitions(t, counterSource)); err == nil {
+		t.Error("Expected an error for a keyword module name")
+	}
+}
+
+func TestGeneratePythonRejectsNFA(t *testing.T) {
+	defs := getDefinitions(t, counterSource+"\n\n"+parallelSource)
+	for name, generate := range map[string]func() error{
+		"module": func() error { return GeneratePython(&strings.Builder{}, defs) },
+		"tests":  func() error { return GeneratePythonTests(&strings.Builder{}, "counter", defs) },
+	} {
+		if err := generate(); err == nil || !strings.Contains(err.Error(), "'tags' is an nfa") {
+			t.Errorf("Expected the %s to fail naming the nfa, got: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package stateflow
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const sqlHeader = "-- Code generated by \"stateflow gen sql\"; DO NOT EDIT.\n"
+
+// GenerateSQL writes a schema enforcing the lifecycle of every automaton
+// on a status column: a table of its states, a table of the allowed
+// (from, to, event) rows, and a trigger rejecting updates of the column
+// that follow no transition. Regex conditions are stored as written, with
+// their slashes. The dialect is postgres or sqlite. The table holding the
+// column defaults to the automaton's name, so naming one requires a
+// single automaton.
+func GenerateSQL(w io.Writer, dialect, table, column string, defs []Definition) error {
+	if dialect != "postgres" && dialect != "sqlite" {
+		return fmt.Errorf("unknown dialect '%s'; use postgres or sqlite", dialect)
+	}
+	graphs := automatonGraphs(defs)
+	if len(graphs) == 0 {
+		return fmt.Errorf("no automaton to generate a schema for")
+	}
+	if table != "" && len(graphs) > 1 {
+		return fmt.Errorf("a table name needs a single automaton, but there are %d", len(graphs))
+	}
+
+	var b strings.Builder
+	b.WriteString(sqlHeader)
+	for _, graph := range graphs {
+		target := table
+		if target == "" {
+			target = graph.name
+		}
+		writeSQLSchema(&b, dialect, graph, target, column)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeSQLSchema(b *strings.Builder, dialect string, graph *automatonGraph, table, column string) {
+	name, kind := graph.name, strings.ToLower(string(graph.kind))
+	fmt.Fprintf(b, "\n-- States of the %s %s\n", kind, name)
+	fmt.Fprintf(b, "CREATE TABLE %s_states (\n    state TEXT PRIMARY KEY,\n    initial BOOLEAN NOT NULL,\n    final BOOLEAN NOT NULL\n);\n\n", name)
+	fmt.Fprintf(b, "INSERT INTO %s_states (state, initial, final) VALUES\n", name)
+	var states []string
+	for i, state := range graph.states {
+		fmt.Fprintf(b, "    (%s, %s, %s)%s\n", sqlString(state.name), sqlBool(state.kind == INITIAL),
+			sqlBool(state.kind == FINAL), sqlSeparator(i, len(graph.states)))
+		states = append(states, sqlString(state.name))
+	}
+
+	type row struct{ from, to, event string }
+	var rows []row
+	seen := make(map[row]bool)
+	for _, t := range graph.transitions {
+		for _, condition := range t.conditions {
+			r := row{t.fromState.lexeme, t.toState.lexeme, conditionText(condition)}
+			if c, ok := condition.(StringCondition); ok {
+				r.event = unquote(c.value)
+			}
+			if !seen[r] {
+				seen[r] = true
+				rows = append(rows, r)
+			}
+		}
+	}
+	fmt.Fprintf(b, "\n-- Transitions of the %s %s, one row per condition\n", kind, name)
+	fmt.Fprintf(b, "CREATE TABLE %[1]s_transitions (\n    from_state TEXT NOT NULL REFERENCES %[1]s_states (state),\n", name)
+	fmt.Fprintf(b, "    to_state TEXT NOT NULL REFERENCES %s_states (state),\n    event TEXT NOT NULL,\n", name)
+	b.WriteString("    PRIMARY KEY (from_state, to_state, event)\n);\n")
+	if len(rows) > 0 {
+		fmt.Fprintf(b, "\nINSERT INTO %s_transitions (from_state, to_state, event) VALUES\n", name)
+		for i, r := range rows {
+			fmt.Fprintf(b, "    (%s, %s, %s)%s\n", sqlString(r.from), sqlString(r.to), sqlString(r.event), sqlSeparator(i, len(rows)))
+		}
+	}
+
+	notState := sqlString(name + ": not a state")
+	rejected := sqlString(name + ": the new " + column + " follows no transition")
+	table, column = sqlIdentifier(table), sqlIdentifier(column)
+	switch dialect {
+	case "postgres":
+		fmt.Fprintf(b, `
+-- Only states of %[1]s may be stored in %[2]s.%[3]s
+ALTER TABLE %[2]s ADD CONSTRAINT %[1]s_state CHECK (%[3]s IN (%[4]s));
+
+-- Rejects updates of %[2]s.%[3]s that follow no transition of %[1]s
+CREATE FUNCTION %[1]s_check_transition() RETURNS trigger AS $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM %[1]s_transitions
+        WHERE from_state = OLD.%[3]s AND to_state = NEW.%[3]s
+    ) THEN
+        RAISE EXCEPTION '%[1]s: no transition from %% to %%', OLD.%[3]s, NEW.%[3]s;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER %[1]s_transition BEFORE UPDATE OF %[3]s ON %[2]s
+FOR EACH ROW WHEN (OLD.%[3]s IS DISTINCT FROM NEW.%[3]s)
+EXECUTE FUNCTION %[1]s_check_transition();
+`, name, table, column, strings.Join(states, ", "))
+	case "sqlite":
+		fmt.Fprintf(b, `
+-- Only states of %[1]s may be stored in %[2]s.%[3]s. SQLite cannot add a
+-- CHECK constraint to an existing table, so a trigger does it.
+CREATE TRIGGER %[1]s_state BEFORE INSERT ON %[2]s
+FOR EACH ROW WHEN NEW.%[3]s NOT IN (SELECT state FROM %[1]s_states)
+BEGIN
+    SELECT RAISE(ABORT, %[4]s);
+END;
+
+-- Rejects updates of %[2]s.%[3]s that follow no transition of %[1]s
+CREATE TRIGGER %[1]s_transition BEFORE UPDATE OF %[3]s ON %[2]s
+FOR EACH ROW WHEN OLD.%[3]s IS NOT NEW.%[3]s AND NOT EXISTS (
+    SELECT 1 FROM %[1]s_transitions
+    WHERE from_state = OLD.%[3]s AND to_state = NEW.%[3]s
+)
+BEGIN
+    SELECT RAISE(ABORT, %[5]s);
+END;
+`, name, table, column, notState, rejected)
+	}
+}
+
+// Ends all but the last row of a VALUES list with a comma
+func sqlSeparator(i, n int) string {
+	if i == n-1 {
+		return ";"
+	}
+	return ","
+}
+
+func sqlBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// Quotes a string as an SQL literal
+func sqlString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// Quotes a name as an SQL identifier, so it may be a keyword
+func sqlIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,48 @@
+package stateflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateSQL(t *testing.T) {
+	defs := getDefinitions(t, counterSource)
+	for _, test := range []struct {
+		dialect  string
+		expected []string
+	}{
+		{"postgres", []string{
+			"    ('q0', TRUE, FALSE),\n",
+			"    ('q1', 'q2', 'inc'),\n    ('q1', 'q2', '/[0-9]+/'),\n",
+			`ALTER TABLE "counters" ADD CONSTRAINT counter_state CHECK ("state" IN ('q0', 'q1', 'q2'));`,
+			"RAISE EXCEPTION 'counter: no transition from % to %', OLD.\"state\", NEW.\"state\";",
+			"FOR EACH ROW WHEN (OLD.\"state\" IS DISTINCT FROM NEW.\"state\")",
+		}},
+		{"sqlite", []string{
+			"CREATE TABLE counter_transitions (\n",
+			"CREATE TRIGGER counter_state BEFORE INSERT ON \"counters\"\n",
+			"SELECT RAISE(ABORT, 'counter: the new state follows no transition');",
+		}},
+	} {
+		var b strings.Builder
+		if err := GenerateSQL(&b, test.dialect, "counters", "state", defs); err != nil {
+			t.Fatalf("Expected no error for %s, got: %v", test.dialect, err)
+		}
+		for _, expected := range test.expected {
+			if !strings.Contains(b.String(), expected) {
+				t.Errorf("Expected %q in %s output:\n%s", expected, test.dialect, b.String())
+			}
+		}
+	}
+}
+
+func TestGenerateSQLErrors(t *testing.T) {
+	defs := getDefinitions(t, counterSource)
+	if err := GenerateSQL(&strings.Builder{}, "mysql", "", "status", defs); err == nil {
+		t.Error("Expected an error for an unknown dialect")
+	}
+	defs = getDefinitions(t, counterSource+"\nnfa other {\n\tinitial q0;\n\tfinal q1;\n\n\ton q0 -> q1 when \"go\";\n}\n")
+	if err := GenerateSQL(&strings.Builder{}, "sqlite", "counters", "status", defs); err == nil {
+		t.Error("Expected an error naming a table for several automata")
+	}
+}
@@ -0,0 +1,185 @@
+package stateflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const tsHeader = "// Code generated by \"stateflow gen ts\"; DO NOT EDIT.\n"
+
+const tsRuntime = `
+const _encoder = new TextEncoder();
+
+// Runs a table indexed by state * width + class over the UTF-8 bytes of
+// the input. Bytes of the same class lead to the same states. State 0
+// rejects every input.
+function _run(next: Uint8Array | Uint16Array, classes: Uint8Array, width: number,
+  accepting: readonly boolean[], start: number, input: string | Uint8Array): boolean {
+  const bytes = typeof input === "string" ? _encoder.encode(input) : input;
+  let state = start;
+  for (let i = 0; i < bytes.length; i++) {
+    state = next[state * width + classes[bytes[i]]];
+    if (state === 0) {
+      return false;
+    }
+  }
+  return accepting[state];
+}
+`
+
+// GenerateTypeScript writes an ES module with, for every automaton among
+// the definitions, State and Event union types and an object whose accepts
+// function matches inputs like the interpreter and whose step function
+// follows the transitions on string conditions. Every automaton must be a
+// dfa.
+func GenerateTypeScript(w io.Writer, defs []Definition) error {
+	if err := rejectNFA(defs, "TypeScript"); err != nil {
+		return err
+	}
+	automata, err := generatedAutomata(defs)
+	if err != nil {
+		return err
+	}
+	graphs := make(map[string]*automatonGraph)
+	for _, graph := range automatonGraphs(defs) {
+		graphs[graph.name] = graph
+	}
+
+	var b strings.Builder
+	b.WriteString(tsHeader)
+	b.WriteString(tsRuntime)
+	// Every name declared at the top of the module, so a dfa cannot shadow
+	// the runtime or the tables of another
+	taken := map[string]string{"_encoder": "the runtime", "_run": "the runtime"}
+	for _, automaton := range automata {
+		graph := graphs[automaton.Name]
+		name, p := automaton.Name, exportedName(automaton.Name)
+		if jsReserved[name] {
+			name += "_"
+		}
+		if p == "" {
+			return fmt.Errorf("'%s' has no letters or digits to name its types after", automaton.Name)
+		}
+		for _, name := range []string{name, name + "Classes", name + "Next", name + "Accepting",
+			name + "Transitions", p + "State", p + "Event"} {
+			if other, ok := taken[name]; ok {
+				return fmt.Errorf("'%s' and %s would both declare %s", automaton.Name, other, name)
+			}
+			taken[name] = "'" + automaton.Name + "'"
+		}
+
+		var states, finals, events []string
+		for _, state := range graph.states {
+			states = append(states, jsString(state.name))
+			if state.kind == FINAL {
+				finals = append(finals, jsString(state.name))
+			}
+		}
+		transitions := make(map[string][]string)
+		seen := make(map[string]bool)
+		for _, t := range graph.transitions {
+			for _, condition := range t.conditions {
+				str, ok := condition.(StringCondition)
+				if !ok {
+					continue
+				}
+				event := jsString(unquote(str.value))
+				if !seen[event] {
+					seen[event] = true
+					events = append(events, event)
+				}
+				transitions[t.fromState.lexeme] = append(transitions[t.fromState.lexeme],
+					event+": "+jsString(t.toState.lexeme))
+			}
+		}
+
+		fmt.Fprintf(&b, "\n// States of the dfa %s\nexport type %sState = %s;\n", automaton.Name, p, tsUnion(states))
+		fmt.Fprintf(&b, "\n// Events of the dfa %s, one per string condition\nexport type %sEvent = %s;\n", automaton.Name, p, tsUnion(events))
+
+		table := automaton.table
+		arrayType := "Uint8Array"
+		if len(table.accept) > 256 {
+			arrayType = "Uint16Array"
+		}
+		fmt.Fprintf(&b, "\nconst %sClasses = new Uint8Array([\n", name)
+		writeNumbers(&b, "  ", table.classes[:])
+		fmt.Fprintf(&b, "]);\n\nconst %sNext = new %s([\n", name, arrayType)
+		for state := range table.accept {
+			fmt.Fprintf(&b, "  // %d\n", state)
+			writeNumbers(&b, "  ", table.row(state))
+		}
+		b.WriteString("]);\n")
+		var accepting []string
+		for _, accept := range table.accept {
+			accepting = append(accepting, fmt.Sprint(accept))
+		}
+		fmt.Fprintf(&b, "\nconst %sAccepting: readonly boolean[] = [%s];\n", name, strings.Join(accepting, ", "))
+
+		fmt.Fprintf(&b, "\nconst %sTransitions: Partial<Record<%sState, Partial<Record<%sEvent, %sState>>>> = {\n",
+			name, p, p, p)
+		for _, state := range graph.states {
+			if entries := transitions[state.name]; len(entries) > 0 {
+				fmt.Fprintf(&b, "  %s: { %s },\n", jsString(state.name), strings.Join(entries, ", "))
+			}
+		}
+		b.WriteString("};\n")
+
+		fmt.Fprintf(&b, `
+// The dfa %[9]s
+export const %[1]s = {
+  name: %[3]s,
+  initial: %[4]s as %[2]sState,
+  states: [%[5]s] as readonly %[2]sState[],
+  finals: [%[6]s] as readonly %[2]sState[],
+  events: [%[7]s] as readonly %[2]sEvent[],
+
+  // Reports whether the dfa accepts the input, matching conditions like
+  // the stateflow interpreter. Strings are read as UTF-8.
+  accepts(input: string | Uint8Array): boolean {
+    return _run(%[1]sNext, %[1]sClasses, %[10]d, %[1]sAccepting, %[8]d, input);
+  },
+
+  // Returns the state the event leads to, or undefined when the state has
+  // no transition on it. Regex conditions have no event.
+  step(state: %[2]sState, event: %[2]sEvent): %[2]sState | undefined {
+    return %[1]sTransitions[state]?.[event];
+  },
+
+  // Reports whether the state is a final state
+  isFinal(state: %[2]sState): boolean {
+    return this.finals.includes(state);
+  },
+};
+`, name, p, jsString(automaton.Name), jsString(automaton.Initial), strings.Join(states, ", "),
+			strings.Join(finals, ", "), strings.Join(events, ", "), table.start, automaton.Name, table.width)
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// Returns a union of literal types, or never when there are none
+func tsUnion(literals []string) string {
+	if len(literals) == 0 {
+		return "never"
+	}
+	return strings.Join(literals, " | ")
+}
+
+// Words that cannot name a constant in an ES module
+var jsReserved = map[string]bool{
+	"await": true, "break": true, "case": true, "catch": true, "class": true, "const": true, "continue": true,
+	"debugger": true, "default": true, "delete": true, "do": true, "else": true, "enum": true, "export": true,
+	"extends": true, "false": true, "finally": true, "for": true, "function": true, "if": true, "implements": true,
+	"import": true, "in": true, "instanceof": true, "interface": true, "let": true, "new": true, "null": true,
+	"package": true, "private": true, "protected": true, "public": true, "return": true, "static": true,
+	"super": true, "switch": true, "this": true, "throw": true, "true": true, "try": true, "typeof": true,
+	"var": true, "void": true, "while": true, "with": true, "yield": true,
+}
+
+// Quotes a string as a JavaScript literal
+func jsString(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
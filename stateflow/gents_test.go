@@ -0,0 +1,89 @@
+package stateflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateTypeScript(t *testing.T) {
+	var b strings.Builder
+	if err := GenerateTypeScript(&b, getDefinitions(t, counterSource)); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	output := b.String()
+	for _, expected := range []string{
+		`export type CounterState = "q0" | "q1" | "q2";`,
+		`export type CounterEvent = "inc" | "reset";`,
+		"const counterNext = new Uint8Array([",
+		`  "q0": { "inc": "q1" },`,
+		"export const counter = {",
+		"step(state: CounterState, event: CounterEvent): CounterState | undefined {",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q in output:\n%s", expected, output)
+		}
+	}
+}
+
+func TestGenerateTypeScriptReservedName(t *testing.T) {
+	source := `dfa class {
+	initial q0;
+	final q1;
+
+	on q0 -> q1 when "go";
+}`
+	var b strings.Builder
+	if err := GenerateTypeScript(&b, getDefinitions(t, source)); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !strings.Contains(b.String(), "export const class_ = {") {
+		t.Errorf("Expected the reserved name to be suffixed:\n%s", b.String())
+	}
+}
+
+func TestGenerateTypeScriptRuntimeName(t *testing.T) {
+	source := `dfa run {
+	initial q0;
+	final q1;
+
+	on q0 -> q1 when "go";
+}`
+	var b strings.Builder
+	if err := GenerateTypeScript(&b, getDefinitions(t, source)); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	output := b.String()
+	for _, expected := range []string{"export const run = {", "return _run(runNext, runClasses,"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q in output:\n%s", expected, output)
+		}
+	}
+}
+
+func TestGenerateTypeScriptNameClash(t *testing.T) {
+	source := `dfa a {
+	initial q0;
+	final q1;
+
+	on q0 -> q1 when "go";
+}
+
+dfa aNext {
+	initial q0;
+	final q1;
+
+	on q0 -> q1 when "go";
+}`
+	err := GenerateTypeScript(&strings.Builder{}, getDefinitions(t, source))
+	if err == nil || !strings.Contains(err.Error(), "would both declare aNext") {
+		t.Errorf("Expected a clash on aNext, got: %v", err)
+	}
+}
+
+func TestGenerateTypeScriptRejectsNFA(t *testing.T) {
+	defs := getDefinitions(t, counterSource+"\n\n"+parallelSource)
+	err := GenerateTypeScript(&strings.Builder{}, defs)
+	if err == nil || !strings.Contains(err.Error(), "'tags' is an nfa") {
+		t.Errorf("Expected an error naming the nfa, got: %v", err)
+	}
+}
@@ -0,0 +1,460 @@
+package stateflow
+
+import (
+	"fmt"
+	"go/token"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A workflow is an automaton used as a typed state machine: its states
+// become a State enum, its string conditions an Event enum, and firing an
+// event takes the transition on it from the current state. Regex
+// conditions have no event to fire, so such automata are rejected.
+type workflow struct {
+	name        string // Automaton name in the source
+	prefix      string // Go name of the machine, prefixing every other name
+	kind        TokenType
+	states      []workflowState
+	initial     int
+	events      []workflowEvent
+	transitions []workflowTransition // In source order
+}
+
+type workflowState struct {
+	source, name string
+	final        bool
+}
+
+type workflowEvent struct {
+	value, name string
+}
+
+type workflowTransition struct {
+	from, event, to int
+}
+
+// GenerateGoWorkflow writes a Go file with a typed state machine for every
+// automaton among the definitions
+func GenerateGoWorkflow(w io.Writer, pkg string, defs []Definition) error {
+	workflows, err := goWorkflows(pkg, defs)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString(goHeader)
+	fmt.Fprintf(&b, "package %s\n\nimport (\n\t\"errors\"\n\t\"fmt\"\n)\n", pkg)
+	for _, wf := range workflows {
+		writeGoWorkflow(&b, wf)
+	}
+	return writeGoSource(w, b.String())
+}
+
+func writeGoWorkflow(b *strings.Builder, wf *workflow) {
+	p, unexported := wf.prefix, unexportedName(wf.prefix)
+	kind := strings.ToLower(string(wf.kind))
+
+	fmt.Fprintf(b, "\n// %sState is a state of the %s %s\ntype %sState int\n\nconst (\n", p, kind, wf.name, p)
+	for i, state := range wf.states {
+		if i == 0 {
+			fmt.Fprintf(b, "\t%s %sState = iota\n", state.name, p)
+		} else {
+			fmt.Fprintf(b, "\t%s\n", state.name)
+		}
+	}
+	fmt.Fprintf(b, ")\n\nvar %sStateNames = [...]string{", unexported)
+	for i, state := range wf.states {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(strconv.Quote(state.source))
+	}
+	b.WriteString("}\n")
+	writeGoEnumString(b, p+"State", unexported+"StateNames")
+
+	var finals []string
+	for _, state := range wf.states {
+		if state.final {
+			finals = append(finals, state.name)
+		}
+	}
+	fmt.Fprintf(b, "\n// IsFinal reports whether the state is a final state\nfunc (s %sState) IsFinal() bool {\n", p)
+	if len(finals) > 0 {
+		fmt.Fprintf(b, "\tswitch s {\n\tcase %s:\n\t\treturn true\n\t}\n", strings.Join(finals, ", "))
+	}
+	b.WriteString("\treturn false\n}\n")
+	fmt.Fprintf(b, `
+// Parse%[1]sState returns the state with the given name, as written in
+// the automaton
+func Parse%[1]sState(name string) (%[1]sState, bool) {
+	for i, stateName := range %[2]sStateNames {
+		if stateName == name {
+			return %[1]sState(i), true
+		}
+	}
+	return 0, false
+}
+`, p, unexported)
+
+	fmt.Fprintf(b, "\n// %sEvent is an event of the %s %s, one per string condition\ntype %sEvent int\n", p, kind, wf.name, p)
+	if len(wf.events) > 0 {
+		b.WriteString("\nconst (\n")
+		for i, event := range wf.events {
+			if i == 0 {
+				fmt.Fprintf(b, "\t%s %sEvent = iota\n", event.name, p)
+			} else {
+				fmt.Fprintf(b, "\t%s\n", event.name)
+			}
+		}
+		b.WriteString(")\n")
+	}
+	fmt.Fprintf(b, "\nvar %sEventNames = [...]string{", unexported)
+	for i, event := range wf.events {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(strconv.Quote(event.value))
+	}
+	b.WriteString("}\n")
+	writeGoEnumString(b, p+"Event", unexported+"EventNames")
+
+	fmt.Fprintf(b, "\nvar %sTransitions = map[%sState]map[%sEvent]%sState{\n", unexported, p, p, p)
+	for from, state := range wf.states {
+		var entries []string
+		for _, t := range wf.transitions {
+			if t.from == from {
+				entries = append(entries, wf.events[t.event].name+": "+wf.states[t.to].name)
+			}
+		}
+		if len(entries) > 0 {
+			fmt.Fprintf(b, "\t%s: {%s},\n", state.name, strings.Join(entries, ", "))
+		}
+	}
+	b.WriteString("}\n")
+
+	fmt.Fprintf(b, `
+// Err%[1]sTransition is returned by Fire for an event the current state
+// has no transition on
+var Err%[1]sTransition = errors.New("%[3]s: no transition")
+
+// %[1]sHook is called by Fire when it takes a transition
+type %[1]sHook func(from %[1]sState, event %[1]sEvent, to %[1]sState)
+
+// %[1]s is a state machine following the transitions of the %[4]s %[3]s.
+// It is not safe for concurrent use.
+type %[1]s struct {
+	state   %[1]sState
+	onEnter map[%[1]sState][]%[1]sHook
+	onExit  map[%[1]sState][]%[1]sHook
+}
+
+// New%[1]s returns a machine in the initial state %[5]s
+func New%[1]s() *%[1]s {
+	return New%[1]sAt(%[6]s)
+}
+
+// New%[1]sAt returns a machine in the given state, such as one loaded
+// from storage
+func New%[1]sAt(state %[1]sState) *%[1]s {
+	return &%[1]s{state: state}
+}
+
+// State returns the current state
+func (m *%[1]s) State() %[1]sState {
+	return m.state
+}
+
+// Can reports whether the current state has a transition on the event
+func (m *%[1]s) Can(event %[1]sEvent) bool {
+	_, ok := %[2]sTransitions[m.state][event]
+	return ok
+}
+
+// Fire takes the transition on the event from the current state, running
+// the exit hooks of the state left and then the enter hooks of the state
+// entered, also on self-loops. When there is no such transition the state
+// is left unchanged and the error wraps Err%[1]sTransition.
+func (m *%[1]s) Fire(event %[1]sEvent) error {
+	to, ok := %[2]sTransitions[m.state][event]
+	if !ok {
+		return fmt.Errorf("%%w on %%s from %%s", Err%[1]sTransition, event, m.state)
+	}
+	from := m.state
+	for _, hook := range m.onExit[from] {
+		hook(from, event, to)
+	}
+	m.state = to
+	for _, hook := range m.onEnter[to] {
+		hook(from, event, to)
+	}
+	return nil
+}
+
+// OnEnter registers a hook called whenever Fire enters the state
+func (m *%[1]s) OnEnter(state %[1]sState, hook %[1]sHook) {
+	if m.onEnter == nil {
+		m.onEnter = make(map[%[1]sState][]%[1]sHook)
+	}
+	m.onEnter[state] = append(m.onEnter[state], hook)
+}
+
+// OnExit registers a hook called whenever Fire leaves the state
+func (m *%[1]s) OnExit(state %[1]sState, hook %[1]sHook) {
+	if m.onExit == nil {
+		m.onExit = make(map[%[1]sState][]%[1]sHook)
+	}
+	m.onExit[state] = append(m.onExit[state], hook)
+}
+`, p, unexported, wf.name, kind, wf.states[wf.initial].source, wf.states[wf.initial].name)
+}
+
+// Writes the String method of an enum backed by a names array
+func writeGoEnumString(b *strings.Builder, typeName, names string) {
+	fmt.Fprintf(b, `
+func (v %[1]s) String() string {
+	if v < 0 || int(v) >= len(%[2]s) {
+		return fmt.Sprintf("%[1]s(%%d)", int(v))
+	}
+	return %[2]s[v]
+}
+`, typeName, names)
+}
+
+// GenerateGoWorkflowTests writes tests for the file written by
+// GenerateGoWorkflow: the shortest way to take every transition, an event
+// that must be refused, and the order hooks run in
+func GenerateGoWorkflowTests(w io.Writer, pkg string, defs []Definition) error {
+	workflows, err := goWorkflows(pkg, defs)
+	if err != nil {
+		return err
+	}
+
+	var body strings.Builder
+	imports := map[string]bool{"testing": true}
+	for _, wf := range workflows {
+		p := wf.prefix
+		paths := wf.paths()
+		fmt.Fprintf(&body, `
+func Test%[1]sTransitions(t *testing.T) {
+	tests := []struct {
+		events []%[1]sEvent
+		state  %[1]sState
+	}{
+		{nil, %[2]s},
+`, p, wf.states[wf.initial].name)
+		for _, transition := range wf.transitions {
+			path, ok := paths[transition.from]
+			if !ok {
+				continue
+			}
+			var events []string
+			for _, event := range append(slices.Clone(path), transition.event) {
+				events = append(events, wf.events[event].name)
+			}
+			fmt.Fprintf(&body, "\t\t{[]%sEvent{%s}, %s},\n", p, strings.Join(events, ", "), wf.states[transition.to].name)
+		}
+		fmt.Fprintf(&body, `	}
+	for _, test := range tests {
+		m := New%[1]s()
+		for _, event := range test.events {
+			if err := m.Fire(event); err != nil {
+				t.Fatalf("%%v: %%v", test.events, err)
+			}
+		}
+		if m.State() != test.state {
+			t.Errorf("%%v: got state %%s, want %%s", test.events, m.State(), test.state)
+		}
+	}
+}
+`, p)
+
+		if from, event, ok := wf.refusedEvent(); ok {
+			imports["errors"] = true
+			fmt.Fprintf(&body, `
+func Test%[1]sRefuses(t *testing.T) {
+	m := New%[1]sAt(%[2]s)
+	if m.Can(%[3]s) {
+		t.Errorf("Expected %[2]s not to accept %[3]s")
+	}
+	if err := m.Fire(%[3]s); !errors.Is(err, Err%[1]sTransition) {
+		t.Errorf("Expected Err%[1]sTransition, got %%v", err)
+	}
+	if m.State() != %[2]s {
+		t.Errorf("Expected the state to stay %[2]s, got %%s", m.State())
+	}
+}
+`, p, wf.states[from].name, wf.events[event].name)
+		}
+
+		for _, transition := range wf.transitions {
+			if transition.from != wf.initial {
+				continue
+			}
+			imports["slices"] = true
+			fmt.Fprintf(&body, `
+func Test%[1]sHooks(t *testing.T) {
+	m := New%[1]s()
+	var calls []string
+	m.OnExit(%[2]s, func(from %[1]sState, event %[1]sEvent, to %[1]sState) {
+		calls = append(calls, "exit "+from.String())
+	})
+	m.OnEnter(%[4]s, func(from %[1]sState, event %[1]sEvent, to %[1]sState) {
+		calls = append(calls, "enter "+to.String())
+	})
+	if err := m.Fire(%[3]s); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"exit %[5]s", "enter %[6]s"}; !slices.Equal(calls, expected) {
+		t.Errorf("Expected hooks %%v, got %%v", expected, calls)
+	}
+}
+`, p, wf.states[transition.from].name, wf.events[transition.event].name, wf.states[transition.to].name,
+				wf.states[transition.from].source, wf.states[transition.to].source)
+			break
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(goHeader)
+	fmt.Fprintf(&b, "package %s\n\nimport (\n", pkg)
+	for _, name := range slices.Sorted(maps.Keys(imports)) {
+		fmt.Fprintf(&b, "\t%s\n", strconv.Quote(name))
+	}
+	b.WriteString(")\n")
+	b.WriteString(body.String())
+	return writeGoSource(w, b.String())
+}
+
+// Returns the shortest events leading into each reachable state
+func (wf *workflow) paths() map[int][]int {
+	paths := map[int][]int{wf.initial: nil}
+	queue := []int{wf.initial}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for _, t := range wf.transitions {
+			if _, ok := paths[t.to]; t.from == state && !ok {
+				paths[t.to] = append(slices.Clone(paths[state]), t.event)
+				queue = append(queue, t.to)
+			}
+		}
+	}
+	return paths
+}
+
+// Returns a state and an event it has no transition on
+func (wf *workflow) refusedEvent() (int, int, bool) {
+	for state := range wf.states {
+		for event := range wf.events {
+			if !slices.ContainsFunc(wf.transitions, func(t workflowTransition) bool {
+				return t.from == state && t.event == event
+			}) {
+				return state, event, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// Builds the workflows of the automata and names their Go identifiers,
+// which must not clash across the file
+func goWorkflows(pkg string, defs []Definition) ([]*workflow, error) {
+	if !token.IsIdentifier(pkg) {
+		return nil, fmt.Errorf("'%s' is not a valid Go package name", pkg)
+	}
+	graphs := automatonGraphs(defs)
+	if len(graphs) == 0 {
+		return nil, fmt.Errorf("no automata to generate code for")
+	}
+
+	taken := make(map[string]string)
+	claim := func(name, what string) error {
+		if name == "" {
+			return fmt.Errorf("%s has no letters or digits to name it after in Go", what)
+		}
+		if other, ok := taken[name]; ok {
+			return fmt.Errorf("%s and %s would both be named %s in Go", what, other, name)
+		}
+		taken[name] = what
+		return nil
+	}
+
+	var workflows []*workflow
+	for _, graph := range graphs {
+		wf := &workflow{name: graph.name, prefix: exportedName(graph.name), kind: graph.kind}
+		what := "automaton '" + graph.name + "'"
+		if err := claim(wf.prefix, what); err != nil {
+			return nil, err
+		}
+		for _, suffix := range []string{"State", "Event", "Hook"} {
+			if err := claim(wf.prefix+suffix, what); err != nil {
+				return nil, err
+			}
+		}
+
+		states := make(map[string]int)
+		wf.initial = -1
+		for i, state := range graph.states {
+			states[state.name] = i
+			if state.kind == INITIAL {
+				wf.initial = i
+			}
+			name := exportedName(state.name)
+			if err := claim(wf.prefix+name, fmt.Sprintf("state '%s' of '%s'", state.name, graph.name)); err != nil {
+				return nil, err
+			}
+			wf.states = append(wf.states, workflowState{source: state.name, name: wf.prefix + name, final: state.kind == FINAL})
+		}
+
+		if wf.initial < 0 {
+			return nil, fmt.Errorf("'%s' has no initial state", graph.name)
+		}
+
+		events := make(map[string]int)
+		targets := make(map[[2]int]int)
+		for _, decl := range graph.transitions {
+			from, to := states[decl.fromState.lexeme], states[decl.toState.lexeme]
+			for _, condition := range decl.conditions {
+				str, ok := condition.(StringCondition)
+				if !ok {
+					return nil, fmt.Errorf("line %d: regex %s of '%s' has no event to fire; workflows need string conditions",
+						decl.Line(), conditionText(condition), graph.name)
+				}
+				value := unquote(str.value)
+				event, ok := events[value]
+				if !ok {
+					event = len(wf.events)
+					events[value] = event
+					name := exportedName(value)
+					if err := claim(wf.prefix+name, fmt.Sprintf("event %s of '%s'", strconv.Quote(value), graph.name)); err != nil {
+						return nil, err
+					}
+					wf.events = append(wf.events, workflowEvent{value: value, name: wf.prefix + name})
+				}
+				if other, ok := targets[[2]int{from, event}]; ok {
+					if other != to {
+						return nil, fmt.Errorf("line %d: state '%s' of '%s' has transitions to both '%s' and '%s' on %s",
+							decl.Line(), decl.fromState.lexeme, graph.name, wf.states[other].source, decl.toState.lexeme, str.value)
+					}
+					continue
+				}
+				targets[[2]int{from, event}] = to
+				wf.transitions = append(wf.transitions, workflowTransition{from, event, to})
+			}
+		}
+		workflows = append(workflows, wf)
+	}
+	return workflows, nil
+}
+
+// unexportedName lowers the first letter of an exported name
+func unexportedName(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r)) + name[size:]
+}
@@ -0,0 +1,102 @@
+package stateflow
+
+import "strings"
+
+// automatonGraph is an automaton seen as a directed graph, used by the
+// exporters. Transitions between the same pair of states are merged into
+// a single edge.
+type automatonGraph struct {
+	name        string
+	token       Token // Name of the automaton in the source
+	kind        TokenType
+	states      []graphState
+	edges       []*graphEdge
+	transitions []*TransDecl // In source order
+}
+
+type graphState struct {
+	name  string
+	kind  TokenType // INITIAL, STATE or FINAL
+	token Token
+}
+
+type graphEdge struct {
+	from, to   string
+	conditions []Condition
+	decls      []*TransDecl
+}
+
+func newAutomatonGraph(def *AutomatonDef) *automatonGraph {
+	graph := &automatonGraph{name: def.name.lexeme, token: def.name, kind: def.autType.tokenType}
+	edges := make(map[[2]string]*graphEdge)
+	for _, stmt := range def.stmts {
+		switch s := stmt.(type) {
+		case *StateDecl:
+			graph.states = append(graph.states, graphState{s.name.lexeme, s.stateType.tokenType, s.name})
+		case *TransDecl:
+			graph.transitions = append(graph.transitions, s)
+			key := [2]string{s.fromState.lexeme, s.toState.lexeme}
+			edge, ok := edges[key]
+			if !ok {
+				edge = &graphEdge{from: key[0], to: key[1]}
+				edges[key] = edge
+				graph.edges = append(graph.edges, edge)
+			}
+			edge.conditions = append(edge.conditions, s.conditions...)
+			edge.decls = append(edge.decls, s)
+		}
+	}
+	return graph
+}
+
+// Returns the index of the state with the given name, or -1
+func (g *automatonGraph) stateIndex(name string) int {
+	for i, state := range g.states {
+		if state.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Reports whether the graph also has the edge going the other way
+func hasOpposite(graph *automatonGraph, edge *graphEdge) bool {
+	for _, other := range graph.edges {
+		if other.from == edge.to && other.to == edge.from {
+			return true
+		}
+	}
+	return false
+}
+
+// automatonGraphs returns the graphs of the automata among the definitions
+func automatonGraphs(defs []Definition) []*automatonGraph {
+	var graphs []*automatonGraph
+	for _, def := range defs {
+		if automaton, ok := def.(*AutomatonDef); ok {
+			graphs = append(graphs, newAutomatonGraph(automaton))
+		}
+	}
+	return graphs
+}
+
+// Returns the conditions of an edge as written in the source, joined
+// with 'or'
+func (e *graphEdge) label() string {
+	var parts []string
+	for _, condition := range e.conditions {
+		parts = append(parts, conditionText(condition))
+	}
+	return strings.Join(parts, " or ")
+}
+
+// conditionText returns a condition as written in the source
+func conditionText(condition Condition) string {
+	switch c := condition.(type) {
+	case StringCondition:
+		return c.value
+	case RegexCondition:
+		return c.pattern
+	}
+	return ""
+}
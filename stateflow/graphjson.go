@@ -0,0 +1,130 @@
+package stateflow
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// GraphVersion is the version of the JSON graph schema. It is bumped
+// whenever a field is renamed or removed.
+const GraphVersion = 1
+
+// The JSON graph of a file is
+//
+//	{
+//	  "version": 1,
+//	  "automata": [
+//	    {
+//	      "name": "counter", "kind": "dfa", "line": 1, "column": 5,
+//	      "initial": "q0",
+//	      "states": [
+//	        {"name": "q0", "type": "initial", "line": 2, "column": 11}
+//	      ],
+//	      "transitions": [
+//	        {"from": "q0", "to": "q1", "line": 6, "column": 6,
+//	         "conditions": [
+//	           {"kind": "string", "value": "inc", "source": "\"inc\""},
+//	           {"kind": "regex", "value": "[0-9]+", "source": "/[0-9]+/"}
+//	         ]}
+//	      ]
+//	    }
+//	  ]
+//	}
+//
+// where "kind" is "dfa" or "nfa", a state "type" is "initial", "state" or
+// "final", and positions are 1-based. There is one transition per `on`
+// statement, in source order, with its position being that of the source
+// state. A condition "value" is the string or pattern without its quotes
+// or slashes, and "source" is the condition as written.
+
+type jsonGraph struct {
+	Version  int             `json:"version"`
+	Automata []jsonAutomaton `json:"automata"`
+}
+
+type jsonAutomaton struct {
+	Name        string           `json:"name"`
+	Kind        string           `json:"kind"`
+	Line        int              `json:"line"`
+	Column      int              `json:"column"`
+	Initial     string           `json:"initial"`
+	States      []jsonState      `json:"states"`
+	Transitions []jsonTransition `json:"transitions"`
+}
+
+type jsonState struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+type jsonTransition struct {
+	From       string          `json:"from"`
+	To         string          `json:"to"`
+	Line       int             `json:"line"`
+	Column     int             `json:"column"`
+	Conditions []jsonCondition `json:"conditions"`
+}
+
+type jsonCondition struct {
+	Kind   string `json:"kind"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// exportJSON writes the automata using the JSON graph schema above
+func exportJSON(w io.Writer, graphs []*automatonGraph) error {
+	document := jsonGraph{Version: GraphVersion, Automata: []jsonAutomaton{}}
+	for _, graph := range graphs {
+		automaton := jsonAutomaton{
+			Name:        graph.name,
+			Kind:        strings.ToLower(string(graph.kind)),
+			Line:        graph.token.line,
+			Column:      graph.token.column,
+			States:      []jsonState{},
+			Transitions: []jsonTransition{},
+		}
+		for _, state := range graph.states {
+			if state.kind == INITIAL {
+				automaton.Initial = state.name
+			}
+			automaton.States = append(automaton.States, jsonState{
+				Name:   state.name,
+				Type:   strings.ToLower(string(state.kind)),
+				Line:   state.token.line,
+				Column: state.token.column,
+			})
+		}
+		for _, t := range graph.transitions {
+			transition := jsonTransition{
+				From:       t.fromState.lexeme,
+				To:         t.toState.lexeme,
+				Line:       t.fromState.line,
+				Column:     t.fromState.column,
+				Conditions: []jsonCondition{},
+			}
+			for _, condition := range t.conditions {
+				transition.Conditions = append(transition.Conditions, newJSONCondition(condition))
+			}
+			automaton.Transitions = append(automaton.Transitions, transition)
+		}
+		document.Automata = append(document.Automata, automaton)
+	}
+
+	data, err := marshalIndent(document)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+func newJSONCondition(condition Condition) jsonCondition {
+	source := conditionText(condition)
+	if _, ok := condition.(RegexCondition); ok {
+		return jsonCondition{Kind: "regex", Value: strings.TrimSuffix(strings.TrimPrefix(source, "/"), "/"), Source: source}
+	}
+	return jsonCondition{Kind: "string", Value: unquote(source), Source: source}
+}
@@ -0,0 +1,212 @@
+package stateflow
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Import reads automata written in another format and returns them as
+// formatted source, checked by the parser like any hand-written file. The
+// name is given to JFLAP automata and unnamed DOT graphs; unnamed SCXML
+// documents are named machine.
+func Import(r io.Reader, format string, name string) ([]byte, error) {
+	var automata []*sourceAutomaton
+	var err error
+	switch format {
+	case "scxml":
+		automata, err = importSCXML(r)
+	case "jflap", "jff":
+		automata, err = importJFLAP(r, name)
+	case "dot":
+		automata, err = importDOT(r, name)
+	default:
+		return nil, fmt.Errorf("unknown import format '%s'", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return writeSource(automata)
+}
+
+// sourceAutomaton is an automaton read from another format, with its
+// conditions already written as source text
+type sourceAutomaton struct {
+	kind        TokenType // DFA, NFA or empty when unknown
+	name        string
+	states      []sourceState
+	transitions []sourceTransition
+}
+
+type sourceState struct {
+	name string
+	kind TokenType // INITIAL, STATE or FINAL
+}
+
+type sourceTransition struct {
+	from, to   string
+	conditions []string // Such as `"inc"` or `/[0-9]+/`
+}
+
+// stringSource writes text as a string condition. Strings have no escapes,
+// so text holding a double quote cannot be written.
+func stringSource(text string) (string, error) {
+	if strings.Contains(text, `"`) {
+		return "", fmt.Errorf("condition %q contains a double quote, which strings cannot hold", text)
+	}
+	return `"` + text + `"`, nil
+}
+
+// regexSource writes a pattern as a regex condition, which cannot hold a
+// slash or a newline
+func regexSource(pattern string) (string, error) {
+	if strings.ContainsAny(pattern, "/\n") {
+		return "", fmt.Errorf("pattern %q contains a slash or a newline, which regexes cannot hold", pattern)
+	}
+	return "/" + pattern + "/", nil
+}
+
+// conditionsSource splits conditions joined with 'or', as written in a
+// transition, making sure the text holds nothing else
+func conditionsSource(text string) ([]string, error) {
+	scanner := Scanner{Source: []byte(text)}
+	tokens, errs := scanner.ScanTokens()
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("conditions %q: %s", text, strings.TrimSpace(errs[0].Error()))
+	}
+	var conditions []string
+	expectCondition := true
+	for _, token := range tokens {
+		switch {
+		case token.tokenType == BOF:
+		case token.tokenType == EOF && !expectCondition:
+			return conditions, nil
+		case expectCondition && (token.tokenType == STRING_LITERAL || token.tokenType == REGEX):
+			conditions = append(conditions, token.lexeme)
+			expectCondition = false
+		case !expectCondition && token.tokenType == OR:
+			expectCondition = true
+		default:
+			return nil, fmt.Errorf("conditions %q: expect strings or regexes joined with 'or'", text)
+		}
+	}
+	return nil, fmt.Errorf("conditions %q: expect strings or regexes joined with 'or'", text)
+}
+
+// namer turns names from other formats into identifiers, always giving
+// the same identifier to the same name and never one already taken
+type namer struct {
+	names map[string]string
+	used  map[string]bool
+}
+
+func newNamer() *namer {
+	return &namer{names: make(map[string]string), used: make(map[string]bool)}
+}
+
+func (n *namer) identifier(name string) string {
+	if id, ok := n.names[name]; ok {
+		return id
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if isAlphanumeric(name[i]) {
+			b.WriteByte(name[i])
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	id := b.String()
+	if id == "" || isDigit(id[0]) {
+		id = "s" + id
+	}
+	if _, ok := keywords[id]; ok {
+		id += "_"
+	}
+	base := id
+	for i := 2; n.used[id]; i++ {
+		id = fmt.Sprintf("%s_%d", base, i)
+	}
+	n.names[name] = id
+	n.used[id] = true
+	return id
+}
+
+// Writes the automata as source, renaming what is not a valid identifier,
+// then checks and formats the result. Automata of unknown kind are written
+// as a dfa when the parser accepts them as one, and as an nfa otherwise.
+func writeSource(automata []*sourceAutomaton) ([]byte, error) {
+	if len(automata) == 0 {
+		return nil, fmt.Errorf("no automata found")
+	}
+	var parts []string
+	automatonNames := newNamer()
+	for _, automaton := range automata {
+		name := automatonNames.identifier(automaton.name)
+		kinds := []TokenType{automaton.kind}
+		if automaton.kind == "" {
+			kinds = []TokenType{DFA, NFA}
+		}
+		var source string
+		var err error
+		for _, kind := range kinds {
+			source, err = automatonSource(automaton, kind, name)
+			if err != nil {
+				return nil, err
+			}
+			if _, err = checkSource(source); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, source)
+	}
+	return checkSource(strings.Join(parts, "\n"))
+}
+
+func automatonSource(automaton *sourceAutomaton, kind TokenType, name string) (string, error) {
+	var b strings.Builder
+	stateNames := newNamer()
+	fmt.Fprintf(&b, "%s %s {\n", strings.ToLower(string(kind)), name)
+	// The initial state goes first, the rest keep their order
+	var states []sourceState
+	for _, state := range automaton.states {
+		if state.kind == INITIAL {
+			states = append([]sourceState{state}, states...)
+		} else {
+			states = append(states, state)
+		}
+	}
+	for _, state := range states {
+		fmt.Fprintf(&b, "%s %s;\n", strings.ToLower(string(state.kind)), stateNames.identifier(state.name))
+	}
+	for _, t := range automaton.transitions {
+		if len(t.conditions) == 0 {
+			return "", fmt.Errorf("transition from %s to %s has no conditions", t.from, t.to)
+		}
+		fmt.Fprintf(&b, "on %s -> %s when %s;\n", stateNames.identifier(t.from), stateNames.identifier(t.to),
+			strings.Join(t.conditions, " or "))
+	}
+	b.WriteString("}\n")
+	return b.String(), nil
+}
+
+// Formats the source and runs it through the scanner and the parser
+func checkSource(text string) ([]byte, error) {
+	source, err := Format([]byte(text))
+	if err != nil {
+		return nil, fmt.Errorf("imported automaton is not valid: %s", strings.TrimSpace(err.Error()))
+	}
+	scanner := Scanner{Source: source}
+	tokens, scanErrs := scanner.ScanTokens()
+	if len(scanErrs) > 0 {
+		return nil, fmt.Errorf("imported automaton is not valid: %s", strings.TrimSpace(scanErrs[0].Error()))
+	}
+	parser := Parser{Tokens: tokens}
+	if _, err := parser.Parse(); err != nil {
+		return nil, fmt.Errorf("imported automaton is not valid: %s", strings.TrimSpace(err.Error()))
+	}
+	return source, nil
+}
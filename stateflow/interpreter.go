@@ -0,0 +1,505 @@
+package stateflow
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Automaton is an automaton definition prepared for simulation.
+//
+// Input is consumed one symbol at a time: from the current state, every
+// condition of the outgoing transitions is matched against the start of
+// the remaining input. A string condition matches its literal text and a
+// regex condition matches the prefix Go's regexp finds first, preferring
+// earlier alternatives over longer ones, so /a|ab/ consumes only a. A DFA
+// takes the transition with the longest match, an NFA follows all of them.
+type Automaton struct {
+	Name        string
+	Kind        TokenType // DFA or NFA
+	Initial     string
+	States      []*StateDecl
+	final       map[string]bool
+	transitions map[string][]*transition // Outgoing transitions by state
+}
+
+type transition struct {
+	decl       *TransDecl
+	conditions []conditionMatcher
+}
+
+type conditionMatcher struct {
+	text    string // Literal text for string conditions
+	pattern *regexp.Regexp
+}
+
+// Returns how many bytes of the input the condition consumes, or -1
+func (c conditionMatcher) match(input string) int {
+	if c.pattern == nil {
+		if c.text != "" && strings.HasPrefix(input, c.text) {
+			return len(c.text)
+		}
+		return -1
+	}
+	loc := c.pattern.FindStringIndex(input)
+	if loc == nil || loc[1] == 0 {
+		return -1
+	}
+	return loc[1]
+}
+
+// NewAutomaton prepares a parsed automaton definition for simulation
+func NewAutomaton(def *AutomatonDef) (*Automaton, error) {
+	automaton := &Automaton{
+		Name:        def.name.lexeme,
+		Kind:        def.autType.tokenType,
+		final:       make(map[string]bool),
+		transitions: make(map[string][]*transition),
+	}
+
+	for _, stmt := range def.stmts {
+		switch s := stmt.(type) {
+		case *StateDecl:
+			automaton.States = append(automaton.States, s)
+			switch s.stateType.tokenType {
+			case INITIAL:
+				automaton.Initial = s.name.lexeme
+			case FINAL:
+				automaton.final[s.name.lexeme] = true
+			}
+		case *TransDecl:
+			t := &transition{decl: s}
+			for _, condition := range s.conditions {
+				switch c := condition.(type) {
+				case StringCondition:
+					t.conditions = append(t.conditions, conditionMatcher{text: unquote(c.value)})
+				case RegexCondition:
+					pattern, err := regexp.Compile(`^(?:` + strings.Trim(c.pattern, "/") + `)`)
+					if err != nil {
+						return nil, RuntimeError{&s.fromState, "Invalid regex " + c.pattern + ": " + err.Error()}
+					}
+					t.conditions = append(t.conditions, conditionMatcher{pattern: pattern})
+				}
+			}
+			automaton.transitions[s.fromState.lexeme] = append(automaton.transitions[s.fromState.lexeme], t)
+		}
+	}
+
+	if automaton.Initial == "" {
+		return nil, RuntimeError{&def.name, "Automaton '" + automaton.Name + "' has no initial state."}
+	}
+	return automaton, nil
+}
+
+// IsFinal reports whether the state is a final state
+func (a *Automaton) IsFinal(state string) bool {
+	return a.final[state]
+}
+
+// Strips the quotes of a string condition
+func unquote(value string) string {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// Transitions returns the transitions leaving the state, in source order
+func (a *Automaton) Transitions(state string) []*TransDecl {
+	var decls []*TransDecl
+	for _, t := range a.transitions[state] {
+		decls = append(decls, t.decl)
+	}
+	return decls
+}
+
+func (t TransDecl) String() string {
+	var conditions []string
+	for _, condition := range t.conditions {
+		switch c := condition.(type) {
+		case StringCondition:
+			conditions = append(conditions, c.value)
+		case RegexCondition:
+			conditions = append(conditions, c.pattern)
+		}
+	}
+	return t.fromState.lexeme + " -> " + t.toState.lexeme + " when " + strings.Join(conditions, " or ")
+}
+
+// Line returns the source line of the transition
+func (t TransDecl) Line() int {
+	return t.fromState.line
+}
+
+func (s StateDecl) String() string {
+	return s.stateType.lexeme + " " + s.name.lexeme
+}
+
+// Name returns the name of the declared state
+func (s StateDecl) Name() string {
+	return s.name.lexeme
+}
+
+// Name returns the name of the automaton
+func (d AutomatonDef) Name() string {
+	return d.name.lexeme
+}
+
+// Name returns the name of the function
+func (d FunctionDef) Name() string {
+	return d.name.lexeme
+}
+
+// Step is one consumed symbol of a run
+type Step struct {
+	Pos        int // Offset of the symbol in the input
+	From       string
+	To         string
+	Symbol     string
+	Transition *TransDecl
+}
+
+// Result is the outcome of running an automaton over an input
+type Result struct {
+	Automaton string
+	Input     string
+	Accepted  bool
+	// Steps of the accepting run, or of the run that consumed the most
+	// input when the input was rejected
+	Steps []Step
+	// Why the input was rejected, nil when it was accepted
+	Rejection *Rejection
+}
+
+// Path returns the states visited by the run, starting at the initial state
+func (r Result) Path(initial string) []string {
+	path := []string{initial}
+	for _, step := range r.Steps {
+		path = append(path, step.To)
+	}
+	return path
+}
+
+type RejectReason string
+
+const (
+	// No transition of the state matches the rest of the input
+	RejectStuck RejectReason = "stuck"
+	// All input was consumed but the run ended in a non-final state
+	RejectNonFinal RejectReason = "non-final"
+)
+
+// Rejection explains why an input was rejected
+type Rejection struct {
+	Reason RejectReason
+	State  string // State the run ended in
+	Pos    int    // Offset of the first input byte that was not consumed
+	Symbol string // Next input character, when stuck
+}
+
+func (r Rejection) String() string {
+	if r.Reason == RejectStuck {
+		return fmt.Sprintf("no transition for symbol %q in state %s (offset %d)", r.Symbol, r.State, r.Pos)
+	}
+	return fmt.Sprintf("input ended in non-final state %s", r.State)
+}
+
+// Configuration is a state the automaton can be in after consuming the
+// first Pos bytes of the input, with the steps that led there
+type Configuration struct {
+	State string
+	Pos   int
+	Steps []Step
+}
+
+// Simulation runs an automaton over an input one step at a time
+type Simulation struct {
+	automaton *Automaton
+	input     string
+	configs   []Configuration
+	// Best rejected run seen so far, reported when nothing accepts
+	furthest Configuration
+}
+
+func NewSimulation(automaton *Automaton, input string) *Simulation {
+	start := Configuration{State: automaton.Initial}
+	return &Simulation{
+		automaton: automaton,
+		input:     input,
+		configs:   []Configuration{start},
+		furthest:  start,
+	}
+}
+
+// Configurations returns the live configurations. A DFA has at most one.
+func (s *Simulation) Configurations() []Configuration {
+	return s.configs
+}
+
+// Done reports whether no configuration can consume more input
+func (s *Simulation) Done() bool {
+	for _, config := range s.configs {
+		if config.Pos < len(s.input) {
+			return false
+		}
+	}
+	return true
+}
+
+// Step fires one transition from every configuration that has input left
+// and returns the steps taken. Configurations without a matching
+// transition are dropped.
+func (s *Simulation) Step() []Step {
+	var next []Configuration
+	var taken []Step
+	type key struct {
+		state string
+		pos   int
+	}
+	seen := make(map[key]bool)
+	add := func(config Configuration) {
+		key := key{config.State, config.Pos}
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		next = append(next, config)
+		if config.Pos > s.furthest.Pos {
+			s.furthest = config
+		}
+	}
+
+	for _, config := range s.configs {
+		if config.Pos >= len(s.input) {
+			add(config)
+			continue
+		}
+		for _, fired := range s.fire(config) {
+			taken = append(taken, fired.Steps[len(fired.Steps)-1])
+			add(fired)
+		}
+	}
+	s.configs = next
+	return taken
+}
+
+// Returns an independent copy of the simulation, used to rewind it
+func (s *Simulation) clone() *Simulation {
+	clone := *s
+	clone.configs = slices.Clone(s.configs)
+	return &clone
+}
+
+// Returns the configurations reached by consuming one symbol
+func (s *Simulation) fire(config Configuration) []Configuration {
+	rest := s.input[config.Pos:]
+	var fired []Configuration
+	bestLength := -1
+	for _, t := range s.automaton.transitions[config.State] {
+		for _, condition := range t.conditions {
+			length := condition.match(rest)
+			if length < 0 {
+				continue
+			}
+			step := Step{
+				Pos:        config.Pos,
+				From:       config.State,
+				To:         t.decl.toState.lexeme,
+				Symbol:     rest[:length],
+				Transition: t.decl,
+			}
+			reached := Configuration{
+				State: step.To,
+				Pos:   config.Pos + length,
+				Steps: append(config.Steps[:len(config.Steps):len(config.Steps)], step),
+			}
+			if s.automaton.Kind != DFA {
+				fired = append(fired, reached)
+			} else if length > bestLength {
+				bestLength = length
+				fired = []Configuration{reached}
+			}
+		}
+	}
+	return fired
+}
+
+// Run steps the simulation until all input is consumed or every run is
+// stuck, then reports whether the input was accepted
+func (s *Simulation) Run() Result {
+	for !s.Done() {
+		s.Step()
+	}
+	return s.Result()
+}
+
+// Result reports the outcome with the input consumed so far
+func (s *Simulation) Result() Result {
+	result := Result{Automaton: s.automaton.Name, Input: s.input, Steps: s.furthest.Steps}
+	for _, config := range s.configs {
+		if config.Pos == len(s.input) && s.automaton.IsFinal(config.State) {
+			result.Accepted = true
+			result.Steps = config.Steps
+			return result
+		}
+	}
+
+	result.Rejection = &Rejection{State: s.furthest.State, Pos: s.furthest.Pos}
+	if s.furthest.Pos < len(s.input) {
+		result.Rejection.Reason = RejectStuck
+		r, _ := utf8.DecodeRuneInString(s.input[s.furthest.Pos:])
+		result.Rejection.Symbol = string(r)
+	} else {
+		result.Rejection.Reason = RejectNonFinal
+	}
+	return result
+}
+
+// Run simulates the automaton over the whole input
+func (a *Automaton) Run(input string) Result {
+	return NewSimulation(a, input).Run()
+}
+
+// Interpreter evaluates programs: automata are run over the values bound
+// to function parameters by the calls in function bodies
+type Interpreter struct {
+	automata    map[string]*Automaton
+	functions   map[string]FunctionDef
+	env         map[string]string
+	invocations []Invocation
+}
+
+// Invocation is an automaton run requested by a call statement
+type Invocation struct {
+	Automaton *Automaton
+	Input     string
+	Call      Call
+}
+
+// Line returns the source line of the call statement, or 0 when the
+// automaton was run directly rather than called from a function
+func (inv Invocation) Line() int {
+	return inv.Call.target.line
+}
+
+func NewInterpreter() *Interpreter {
+	return &Interpreter{
+		automata:  make(map[string]*Automaton),
+		functions: make(map[string]FunctionDef),
+	}
+}
+
+// Define registers parsed definitions. Nothing is registered if any
+// definition is rejected. Names are not checked again here: the parser
+// already rejects a name defined earlier, so the REPL refuses to redefine
+// an automaton or function.
+func (i *Interpreter) Define(defs []Definition) error {
+	automata := maps.Clone(i.automata)
+	functions := maps.Clone(i.functions)
+	for _, def := range defs {
+		if _, err := def.Accept(i); err != nil {
+			i.automata = automata
+			i.functions = functions
+			return err
+		}
+	}
+	return nil
+}
+
+// Automaton returns the automaton with the given name, or nil
+func (i *Interpreter) Automaton(name string) *Automaton {
+	return i.automata[name]
+}
+
+// Automata returns the names of the defined automata, sorted
+func (i *Interpreter) Automata() []string {
+	return slices.Sorted(maps.Keys(i.automata))
+}
+
+// Functions returns the names of the defined functions, sorted
+func (i *Interpreter) Functions() []string {
+	return slices.Sorted(maps.Keys(i.functions))
+}
+
+// Function returns the function with the given name
+func (i *Interpreter) Function(name string) (FunctionDef, bool) {
+	function, ok := i.functions[name]
+	return function, ok
+}
+
+// Call evaluates the body of a function with the given arguments and
+// returns the result of every automaton it runs, in order
+func (i *Interpreter) Call(name string, args []string) ([]Result, error) {
+	invocations, err := i.Invocations(name, args)
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, invocation := range invocations {
+		results = append(results, invocation.Automaton.Run(invocation.Input))
+	}
+	return results, nil
+}
+
+// Invocations evaluates the body of a function with the given arguments
+// and returns the automaton runs it requests, without running them
+func (i *Interpreter) Invocations(name string, args []string) ([]Invocation, error) {
+	function, ok := i.functions[name]
+	if !ok {
+		return nil, fmt.Errorf("Undefined function '%s'.", name)
+	}
+	if len(args) != len(function.params) {
+		return nil, RuntimeError{&function.name, "Function '" + name + "' expects " +
+			pluralize(len(function.params), "argument") + fmt.Sprintf(" but got %d.", len(args))}
+	}
+
+	i.env = make(map[string]string)
+	for index, param := range function.params {
+		i.env[param.lexeme] = args[index]
+	}
+	i.invocations = nil
+	for _, statement := range function.statements {
+		if _, err := statement.Accept(i); err != nil {
+			return nil, err
+		}
+	}
+	return i.invocations, nil
+}
+
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func (i *Interpreter) VisitAutomatonDefDefinition(definition AutomatonDef) (any, error) {
+	automaton, err := NewAutomaton(&definition)
+	if err != nil {
+		return nil, err
+	}
+	i.automata[automaton.Name] = automaton
+	return automaton, nil
+}
+
+func (i *Interpreter) VisitFunctionDefDefinition(definition FunctionDef) (any, error) {
+	i.functions[definition.name.lexeme] = definition
+	return nil, nil
+}
+
+func (i *Interpreter) VisitCallStatement(statement Call) (any, error) {
+	automaton, ok := i.automata[statement.target.lexeme]
+	if !ok {
+		return nil, RuntimeError{&statement.target, "Undefined automaton '" + statement.target.lexeme + "'."}
+	}
+	input, ok := i.env[statement.input.lexeme]
+	if !ok {
+		return nil, RuntimeError{&statement.input, "Undefined variable '" + statement.input.lexeme + "'."}
+	}
+	invocation := Invocation{Automaton: automaton, Input: input, Call: statement}
+	i.invocations = append(i.invocations, invocation)
+	return invocation, nil
+}
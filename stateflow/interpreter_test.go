@@ -0,0 +1,274 @@
+package stateflow
+
+import (
+	"strings"
+	"testing"
+)
+
+// Helper function to parse a program and load it into an interpreter
+func getInterpreter(t *testing.T, source string) *Interpreter {
+	parser := Parser{Tokens: getTokens(source)}
+	defs, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Expected no parse error, got: %v", err)
+	}
+	interpreter := NewInterpreter()
+	if err := interpreter.Define(defs); err != nil {
+		t.Fatalf("Expected no error defining, got: %v", err)
+	}
+	return interpreter
+}
+
+const counterSource = `dfa counter {
+	initial q0;
+	state q1;
+	final q2;
+
+	on q0 -> q1 when "inc";
+	on q1 -> q2 when "inc" or /[0-9]+/;
+	on q2 -> q2 when "reset";
+}
+
+fn main(input, other) {
+	counter <- input;
+	counter <- other;
+}`
+
+func TestRunDFA(t *testing.T) {
+	automaton := getInterpreter(t, counterSource).Automaton("counter")
+
+	tests := []struct {
+		input    string
+		accepted bool
+		path     string
+	}{
+		{"incinc", true, "q0 q1 q2"},
+		{"inc123", true, "q0 q1 q2"},
+		{"incincreset", true, "q0 q1 q2 q2"},
+		{"inc", false, "q0 q1"},
+		{"", false, "q0"},
+		{"incx", false, "q0 q1"},
+	}
+	for _, test := range tests {
+		result := automaton.Run(test.input)
+		if result.Accepted != test.accepted {
+			t.Errorf("%q: expected accepted=%v", test.input, test.accepted)
+		}
+		if path := strings.Join(result.Path(automaton.Initial), " "); path != test.path {
+			t.Errorf("%q: expected path %q, got %q", test.input, test.path, path)
+		}
+	}
+}
+
+func TestRunLongestMatch(t *testing.T) {
+	automaton := getInterpreter(t, `dfa words {
+		initial q0;
+		final short;
+		final long;
+		on q0 -> short when "a";
+		on q0 -> long when "ab";
+	}`).Automaton("words")
+
+	result := automaton.Run("ab")
+	if !result.Accepted || result.Steps[0].To != "long" {
+		t.Errorf("Expected the longest condition to win, got %+v", result)
+	}
+}
+
+func TestInterpreterCall(t *testing.T) {
+	interpreter := getInterpreter(t, counterSource)
+
+	results, err := interpreter.Call("main", []string{"incinc", "inc"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(results) != 2 || !results[0].Accepted || results[1].Accepted {
+		t.Errorf("Expected accept then reject, got %+v", results)
+	}
+
+	if _, err := interpreter.Call("main", []string{"inc"}); err == nil {
+		t.Error("Expected error for wrong number of arguments")
+	}
+	if _, err := interpreter.Call("missing", nil); err == nil {
+		t.Error("Expected error for undefined function")
+	}
+}
+
+func TestInterpreterUndefinedAutomaton(t *testing.T) {
+	interpreter := getInterpreter(t, `fn main(x) {
+		nothing <- x;
+	}`)
+
+	if _, err := interpreter.Call("main", []string{"a"}); err == nil {
+		t.Error("Expected error for undefined automaton")
+	}
+}
+
+func TestREPLSession(t *testing.T) {
+	var out strings.Builder
+	repl := NewREPL(&out)
+	input := strings.Join([]string{
+		"dfa ab {",
+		"  initial q0; final q1;",
+		"  on q0 -> q1 when \"a\";",
+		"}",
+		"a",
+		"b",
+		"dfa ab { initial s; final t; on s -> t when \"b\"; }",
+		"fn main(x) { ab <- x; }",
+		":run main a",
+		":quit",
+		"ignored",
+	}, "\n")
+	if err := repl.Run(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"defined dfa ab (2 states)",
+		"accepted: q0 -> q1",
+		"rejected: q0 (no transition for symbol \"b\" in state q0 (offset 0))",
+		"Symbol 'ab' already defined",
+		"defined fn main(x)",
+		"ab <- \"a\"",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in output:\n%s", expected, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "ignored") {
+		t.Error("Expected :quit to end the session")
+	}
+}
+
+func TestRejectionReasons(t *testing.T) {
+	automaton := getInterpreter(t, counterSource).Automaton("counter")
+
+	stuck := automaton.Run("incx")
+	if stuck.Rejection == nil || stuck.Rejection.Reason != RejectStuck ||
+		stuck.Rejection.State != "q1" || stuck.Rejection.Symbol != "x" || stuck.Rejection.Pos != 3 {
+		t.Errorf("Expected to get stuck in q1 on 'x', got %+v", stuck.Rejection)
+	}
+
+	nonFinal := automaton.Run("inc")
+	if nonFinal.Rejection == nil || nonFinal.Rejection.Reason != RejectNonFinal || nonFinal.Rejection.State != "q1" {
+		t.Errorf("Expected to end in non-final q1, got %+v", nonFinal.Rejection)
+	}
+
+	if accepted := automaton.Run("incinc"); accepted.Rejection != nil {
+		t.Errorf("Expected no rejection, got %+v", accepted.Rejection)
+	}
+}
+
+func TestTraceOutput(t *testing.T) {
+	automaton := getInterpreter(t, counterSource).Automaton("counter")
+	result := automaton.Run("incx")
+
+	var table strings.Builder
+	if err := WriteTrace(&table, result); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`1  0       q0    "inc"   q1  q0 -> q1 when "inc"  6`,
+		`rejected: no transition for symbol "x" in state q1 (offset 3)`,
+	} {
+		if !strings.Contains(table.String(), expected) {
+			t.Errorf("Expected %q in table:\n%s", expected, table.String())
+		}
+	}
+
+	data, err := MarshalTraces([]Result{result})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact := strings.Join(strings.Fields(string(data)), "")
+	for _, expected := range []string{
+		`"transition":"q0->q1when\"inc\"","line":6`,
+		`"rejection":{"reason":"stuck","state":"q1","offset":3,"symbol":"x"`,
+	} {
+		if !strings.Contains(compact, expected) {
+			t.Errorf("Expected %s in JSON:\n%s", expected, data)
+		}
+	}
+}
+
+const endsWithBSource = `nfa ends {
+	initial s0;
+	final s1;
+
+	on s0 -> s0 when /[ab]/;
+	on s0 -> s1 when "b";
+}
+
+fn main(x, y) {
+	ends <- x;
+	ends <- y;
+}`
+
+func TestRunNFA(t *testing.T) {
+	automaton := getInterpreter(t, endsWithBSource).Automaton("ends")
+
+	for input, accepted := range map[string]bool{"ab": true, "abab": true, "ba": false, "": false} {
+		if result := automaton.Run(input); result.Accepted != accepted {
+			t.Errorf("%q: expected accepted=%v", input, accepted)
+		}
+	}
+
+	sim := NewSimulation(automaton, "ab")
+	sim.Step()
+	sim.Step()
+	if configs := sim.Configurations(); len(configs) != 2 {
+		t.Errorf("Expected states {s0, s1} after 'ab', got %+v", configs)
+	}
+}
+
+func TestDebuggerBreakpointsAndRewind(t *testing.T) {
+	interpreter := getInterpreter(t, endsWithBSource)
+	invocations, err := interpreter.Invocations("main", []string{"ab", "bb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	debugger := NewDebugger(&out, invocations)
+	commands := "break state s1\nc\nc\nc\ninfo\nrewind\nq\n"
+	if err := debugger.Run(strings.NewReader(commands)); err != nil {
+		t.Fatal(err)
+	}
+
+	output := out.String()
+	// Set once, then hit in both calls of main
+	if strings.Count(output, "Breakpoint #1 enter state s1\n") != 4 {
+		t.Errorf("Expected the breakpoint to be set once and hit three times:\n%s", output)
+	}
+	for _, expected := range []string{
+		"[1/2] ends <- \"ab\" (line 10)\n  states: {s0@2, s1*@2}",
+		"ends <- \"ab\": accepted",
+		"[2/2] ends <- \"bb\" (line 11)\n  states: {s0@2, s1*@2}\n(sfdb) [2/2] ends <- \"bb\" (line 11)\n  states: {s0@1, s1*@1}",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q in output:\n%s", expected, output)
+		}
+	}
+}
+
+func TestDebuggerDirectRun(t *testing.T) {
+	automaton := getInterpreter(t, endsWithBSource).Automaton("ends")
+
+	var out strings.Builder
+	debugger := NewDebugger(&out, []Invocation{{Automaton: automaton, Input: "ab"}})
+	if err := debugger.Run(strings.NewReader("step 1 2\nrewind 1 2\ninfo\nq\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	output := out.String()
+	// Without a call statement there is no line to show
+	for _, expected := range []string{"Usage: step [n]\n", "Usage: rewind [n]\n", "[1/1] ends <- \"ab\"\n  states: {s0@0}"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q in output:\n%s", expected, output)
+		}
+	}
+	if strings.Contains(output, "line 0") {
+		t.Errorf("Expected no line for a direct run:\n%s", output)
+	}
+}
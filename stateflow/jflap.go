@@ -0,0 +1,231 @@
+package stateflow
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+)
+
+// JFLAP stores a finite automaton as a <structure> of type "fa" holding
+// numbered <state> elements, with coordinates and <initial/> or <final/>
+// markers, and <transition> elements that each read one string. A
+// transition with several conditions becomes several JFLAP transitions.
+// JFLAP has no regexes, so a regex condition is exported only when it
+// matches a small finite set of strings, such as /[abc]/ or /yes|no/.
+
+// Spacing of the exported states, in JFLAP's pixels
+const (
+	jflapMargin = 80.0
+	jflapColumn = 150.0
+	jflapRow    = 120.0
+)
+
+// maxRegexAlternatives bounds the strings a regex may expand into
+const maxRegexAlternatives = 256
+
+// exportJFLAP writes an automaton as a JFLAP file, placing its states with
+// the layered layout. A file holds a single automaton.
+func exportJFLAP(w io.Writer, graphs []*automatonGraph) error {
+	if len(graphs) > 1 {
+		return fmt.Errorf("a JFLAP file holds one automaton; name one with file.sf:name")
+	}
+	graph := graphs[0]
+	layout := layoutGraph(graph)
+
+	var b strings.Builder
+	b.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"no\"?>\n")
+	fmt.Fprintf(&b, "<!--Exported from stateflow %s %s-->\n", strings.ToLower(string(graph.kind)), xmlCommentEscaper.Replace(graph.name))
+	b.WriteString("<structure>\n\t<type>fa</type>\n\t<automaton>\n")
+	ids := make(map[string]int)
+	for i, state := range graph.states {
+		ids[state.name] = i
+		p := layout.position[state.name]
+		fmt.Fprintf(&b, "\t\t<state id=\"%d\" name=\"%s\">\n", i, xmlEscaper.Replace(state.name))
+		fmt.Fprintf(&b, "\t\t\t<x>%.1f</x>\n\t\t\t<y>%.1f</y>\n", jflapMargin+p.X*jflapColumn, jflapMargin+p.Y*jflapRow)
+		switch state.kind {
+		case INITIAL:
+			b.WriteString("\t\t\t<initial/>\n")
+		case FINAL:
+			b.WriteString("\t\t\t<final/>\n")
+		}
+		b.WriteString("\t\t</state>\n")
+	}
+	for _, t := range graph.transitions {
+		for _, condition := range t.conditions {
+			var reads []string
+			switch c := condition.(type) {
+			case StringCondition:
+				reads = []string{unquote(c.value)}
+			case RegexCondition:
+				alternatives, ok := regexAlternatives(c.pattern)
+				if !ok {
+					return fmt.Errorf("line %d: regex %s has no JFLAP equivalent", t.Line(), c.pattern)
+				}
+				reads = alternatives
+			}
+			for _, read := range reads {
+				fmt.Fprintf(&b, "\t\t<transition>\n\t\t\t<from>%d</from>\n\t\t\t<to>%d</to>\n\t\t\t<read>%s</read>\n\t\t</transition>\n",
+					ids[t.fromState.lexeme], ids[t.toState.lexeme], xmlEscaper.Replace(read))
+			}
+		}
+	}
+	b.WriteString("\t</automaton>\n</structure>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// regexAlternatives lists the strings a regex matches when it is a literal,
+// a character class or an alternation of those
+func regexAlternatives(pattern string) ([]string, bool) {
+	re, err := syntax.Parse(strings.TrimSuffix(strings.TrimPrefix(pattern, "/"), "/"), syntax.Perl)
+	if err != nil {
+		return nil, false
+	}
+	var alternatives []string
+	var expand func(*syntax.Regexp) bool
+	expand = func(re *syntax.Regexp) bool {
+		switch re.Op {
+		case syntax.OpLiteral:
+			if re.Flags&syntax.FoldCase != 0 {
+				return false
+			}
+			alternatives = append(alternatives, string(re.Rune))
+		case syntax.OpCharClass:
+			for i := 0; i+1 < len(re.Rune); i += 2 {
+				if int(re.Rune[i+1]-re.Rune[i])+len(alternatives) >= maxRegexAlternatives {
+					return false
+				}
+				for r := re.Rune[i]; r <= re.Rune[i+1]; r++ {
+					alternatives = append(alternatives, string(r))
+				}
+			}
+		case syntax.OpAlternate, syntax.OpCapture:
+			for _, sub := range re.Sub {
+				if !expand(sub) {
+					return false
+				}
+			}
+		default:
+			return false
+		}
+		return len(alternatives) <= maxRegexAlternatives
+	}
+	if !expand(re.Simplify()) {
+		return nil, false
+	}
+	return alternatives, true
+}
+
+// importJFLAP reads a JFLAP finite automaton, which has no name of its
+// own. Transitions reading the empty string have no counterpart and are
+// rejected.
+func importJFLAP(r io.Reader, name string) ([]*sourceAutomaton, error) {
+	var root xmlNode
+	if err := xml.NewDecoder(r).Decode(&root); err != nil {
+		return nil, fmt.Errorf("reading JFLAP file: %w", err)
+	}
+	if root.XMLName.Local != "structure" {
+		return nil, fmt.Errorf("expected a JFLAP <structure>, found <%s>", root.XMLName.Local)
+	}
+
+	// Older files put the states right under <structure>
+	body := root
+	for _, child := range root.Children {
+		switch child.XMLName.Local {
+		case "type":
+			if kind := child.text(); kind != "fa" {
+				return nil, fmt.Errorf("only finite automata can be imported, found type '%s'", kind)
+			}
+		case "automaton":
+			body = child
+		}
+	}
+
+	automaton := &sourceAutomaton{name: name}
+	names := make(map[string]string)
+	taken := make(map[string]bool)
+	for _, child := range body.Children {
+		if child.XMLName.Local != "state" {
+			continue
+		}
+		// Names are labels in JFLAP and may repeat, unlike ids
+		id := child.attr("id")
+		stateName := child.attr("name")
+		if stateName == "" || taken[stateName] {
+			stateName = "q" + id
+			for i := 2; taken[stateName]; i++ {
+				stateName = fmt.Sprintf("q%s_%d", id, i)
+			}
+		}
+		taken[stateName] = true
+		names[id] = stateName
+		state := sourceState{name: stateName, kind: STATE}
+		for _, marker := range child.Children {
+			switch marker.XMLName.Local {
+			case "initial":
+				if state.kind == FINAL {
+					return nil, fmt.Errorf("state '%s' cannot be both initial and final", stateName)
+				}
+				state.kind = INITIAL
+			case "final":
+				if state.kind == INITIAL {
+					return nil, fmt.Errorf("state '%s' cannot be both initial and final", stateName)
+				}
+				state.kind = FINAL
+			}
+		}
+		automaton.states = append(automaton.states, state)
+	}
+	if len(automaton.states) == 0 {
+		return nil, fmt.Errorf("the file has no states")
+	}
+
+	// Transitions between the same states are joined with 'or'
+	index := make(map[[2]string]int)
+	for _, child := range body.Children {
+		if child.XMLName.Local != "transition" {
+			continue
+		}
+		var from, to, read string
+		hasRead := false
+		for _, field := range child.Children {
+			switch field.XMLName.Local {
+			case "from":
+				from = field.text()
+			case "to":
+				to = field.text()
+			case "read":
+				read, hasRead = field.Text, true
+			}
+		}
+		fromName, ok := names[from]
+		if !ok {
+			return nil, fmt.Errorf("transition from unknown state %s", strconv.Quote(from))
+		}
+		toName, ok := names[to]
+		if !ok {
+			return nil, fmt.Errorf("transition to unknown state %s", strconv.Quote(to))
+		}
+		if !hasRead || read == "" {
+			return nil, fmt.Errorf("state '%s': empty (lambda) transitions are not supported", fromName)
+		}
+		condition, err := stringSource(read)
+		if err != nil {
+			return nil, err
+		}
+
+		key := [2]string{fromName, toName}
+		if i, ok := index[key]; ok {
+			automaton.transitions[i].conditions = append(automaton.transitions[i].conditions, condition)
+			continue
+		}
+		index[key] = len(automaton.transitions)
+		automaton.transitions = append(automaton.transitions, sourceTransition{fromName, toName, []string{condition}})
+	}
+	return []*sourceAutomaton{automaton}, nil
+}
+
+var xmlCommentEscaper = strings.NewReplacer("--", "- -")
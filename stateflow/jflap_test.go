@@ -0,0 +1,144 @@
+package stateflow
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestJFLAPRoundTrip(t *testing.T) {
+	source := `dfa parity {
+  initial even;
+  state odd;
+  final done;
+
+  on even -> odd  when "1";
+  on even -> even when "0";
+  on odd -> even  when "1";
+  on odd -> odd   when "0";
+  on odd -> done  when "x";
+}
+`
+	jff := export(t, "jflap", source)
+
+	for _, fragment := range []string{
+		"<type>fa</type>",
+		"<state id=\"0\" name=\"even\">\n\t\t\t<x>80.0</x>\n\t\t\t<y>80.0</y>\n\t\t\t<initial/>",
+		"<state id=\"2\" name=\"done\">",
+		"<final/>",
+		"<from>1</from>\n\t\t\t<to>2</to>\n\t\t\t<read>x</read>",
+	} {
+		if !strings.Contains(jff, fragment) {
+			t.Errorf("Expected %q in output:\n%s", fragment, jff)
+		}
+	}
+
+	imported, err := Import(strings.NewReader(jff), "jflap", "parity")
+	if err != nil {
+		t.Fatalf("Expected no error importing, got: %v", err)
+	}
+	if string(imported) != source {
+		t.Errorf("Expected the round trip to give back the source, got:\n%s", imported)
+	}
+}
+
+func TestJFLAPExportExpandsRegexes(t *testing.T) {
+	jff := export(t, "jflap", `nfa digits {
+	initial q0;
+	final q1;
+
+	on q0 -> q1 when /[0-2]|yes/;
+}`)
+	var reads []string
+	for _, part := range strings.Split(jff, "<read>")[1:] {
+		reads = append(reads, part[:strings.Index(part, "</read>")])
+	}
+	if !slices.Equal(reads, []string{"0", "1", "2", "yes"}) {
+		t.Errorf("Expected the regex to expand to 0, 1, 2 and yes, got %v", reads)
+	}
+
+	var b strings.Builder
+	if err := Export(&b, "jflap", getDefinitions(t, counterSource)); err == nil ||
+		!strings.Contains(err.Error(), "/[0-9]+/ has no JFLAP equivalent") {
+		t.Errorf("Expected an error for an unbounded regex, got: %v", err)
+	}
+}
+
+func TestJFLAPImport(t *testing.T) {
+	file := `<?xml version="1.0" encoding="UTF-8" standalone="no"?><!--Created with JFLAP 7.1.--><structure>&#13;
+	<type>fa</type>&#13;
+	<automaton>&#13;
+		<state id="0" name="q0"><x>60.0</x><y>80.0</y><initial/></state>&#13;
+		<state id="1" name="q1"><x>160.0</x><y>80.0</y></state>&#13;
+		<state id="2" name="q1"><x>260.0</x><y>80.0</y><final/></state>&#13;
+		<transition><from>0</from><to>1</to><read>a</read></transition>&#13;
+		<transition><from>0</from><to>2</to><read>a</read></transition>&#13;
+		<transition><from>1</from><to>2</to><read>b</read></transition>&#13;
+		<transition><from>1</from><to>2</to><read>c</read></transition>&#13;
+	</automaton>&#13;
+</structure>`
+
+	// Reading "a" from q0 is non-deterministic, and the repeated name q1
+	// falls back to the id
+	expected := `nfa lab_3 {
+  initial q0;
+  state q1;
+  final q2;
+
+  on q0 -> q1 when "a";
+  on q0 -> q2 when "a";
+  on q1 -> q2 when "b" or "c";
+}
+`
+	imported, err := Import(strings.NewReader(file), "jflap", "lab-3")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if string(imported) != expected {
+		t.Errorf("Unexpected source:\n%s", imported)
+	}
+}
+
+func TestJFLAPImportRepeatedNameOfAnId(t *testing.T) {
+	file := `<structure><type>fa</type><automaton>
+		<state id="0" name="q1"><initial/></state>
+		<state id="1" name="q1"><final/></state>
+		<transition><from>0</from><to>1</to><read>a</read></transition>
+		<transition><from>1</from><to>1</to><read>a</read></transition>
+	</automaton></structure>`
+
+	// The second q1 falls back to its id, which is the name already taken
+	expected := `dfa machine {
+  initial q1;
+  final q1_2;
+
+  on q1 -> q1_2   when "a";
+  on q1_2 -> q1_2 when "a";
+}
+`
+	imported, err := Import(strings.NewReader(file), "jflap", "machine")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if string(imported) != expected {
+		t.Errorf("Unexpected source:\n%s", imported)
+	}
+}
+
+func TestJFLAPImportErrors(t *testing.T) {
+	tests := []struct {
+		file    string
+		message string
+	}{
+		{`<structure><type>pda</type></structure>`, "only finite automata"},
+		{`<structure><type>fa</type><automaton><state id="0"><initial/></state><transition><from>0</from><to>0</to><read/></transition></automaton></structure>`, "lambda"},
+		{`<structure><type>fa</type><automaton><state id="0"><initial/><final/></state></automaton></structure>`, "both initial and final"},
+		{`<structure><type>fa</type><automaton><state id="0"><initial/></state><transition><from>0</from><to>7</to><read>a</read></transition></automaton></structure>`, "unknown state"},
+	}
+	for _, test := range tests {
+		_, err := Import(strings.NewReader(test.file), "jflap", "machine")
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Expected an error containing %q for %s, got: %v", test.message, test.file, err)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package stateflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ASTVersion is the version of the JSON schema produced by MarshalAST.
+// It is bumped whenever a field is renamed or removed.
+const ASTVersion = 1
+
+// The JSON schema of a parsed program is:
+//
+//	{
+//	  "version": 1,
+//	  "definitions": [
+//	    {"kind": "AutomatonDef", "type": Token, "name": Token, "stmts": [Stmt...]},
+//	    {"kind": "FunctionDef", "name": Token, "params": [Token...], "statements": [Statement...]}
+//	  ]
+//	}
+//
+// where a Stmt is one of
+//
+//	{"kind": "StateDecl", "type": Token, "name": Token}
+//	{"kind": "TransDecl", "from": Token, "to": Token, "conditions": [Condition...]}
+//
+// a Statement is
+//
+//	{"kind": "Call", "target": Token, "input": Token}
+//
+// a Condition is one of
+//
+//	{"kind": "StringCondition", "value": "\"inc\""}
+//	{"kind": "RegexCondition", "pattern": "/[a-z]/"}
+//
+// and a Token is
+//
+//	{"type": "IDENTIFIER", "lexeme": "q0", "line": 3, "column": 11}
+//
+// Condition values and patterns keep their quotes and slashes, exactly as
+// they appear in the source.
+
+type jsonAST struct {
+	Version     int        `json:"version"`
+	Definitions []jsonNode `json:"definitions"`
+}
+
+type jsonToken struct {
+	Type   TokenType `json:"type"`
+	Lexeme string    `json:"lexeme"`
+	Line   int       `json:"line"`
+	Column int       `json:"column"`
+}
+
+type jsonNode struct {
+	Kind       string      `json:"kind"`
+	Type       *jsonToken  `json:"type,omitempty"`
+	Name       *jsonToken  `json:"name,omitempty"`
+	From       *jsonToken  `json:"from,omitempty"`
+	To         *jsonToken  `json:"to,omitempty"`
+	Target     *jsonToken  `json:"target,omitempty"`
+	Input      *jsonToken  `json:"input,omitempty"`
+	Params     []jsonToken `json:"params,omitempty"`
+	Stmts      []jsonNode  `json:"stmts,omitempty"`
+	Statements []jsonNode  `json:"statements,omitempty"`
+	Conditions []jsonNode  `json:"conditions,omitempty"`
+	Value      string      `json:"value,omitempty"`
+	Pattern    string      `json:"pattern,omitempty"`
+}
+
+// MarshalAST encodes parsed definitions using the JSON schema above
+func MarshalAST(defs []Definition) ([]byte, error) {
+	encoder := astEncoder{}
+	ast := jsonAST{Version: ASTVersion, Definitions: []jsonNode{}}
+	for _, def := range defs {
+		node, err := def.Accept(encoder)
+		if err != nil {
+			return nil, err
+		}
+		ast.Definitions = append(ast.Definitions, node.(jsonNode))
+	}
+	return json.MarshalIndent(ast, "", "  ")
+}
+
+// Like json.MarshalIndent but leaves '<', '>' and '&' unescaped, for
+// traces and graphs, which are read by people as much as by programs
+func marshalIndent(v any) ([]byte, error) {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(b.Bytes(), "\n"), nil
+}
+
+// UnmarshalAST reconstructs the definitions encoded by MarshalAST
+func UnmarshalAST(data []byte) ([]Definition, error) {
+	var ast jsonAST
+	if err := json.Unmarshal(data, &ast); err != nil {
+		return nil, err
+	}
+	if ast.Version != ASTVersion {
+		return nil, fmt.Errorf("unsupported AST version %d", ast.Version)
+	}
+
+	var defs []Definition
+	for _, node := range ast.Definitions {
+		def, err := decodeDefinition(node)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+type astEncoder struct{}
+
+func encodeToken(t Token) *jsonToken {
+	return &jsonToken{Type: t.tokenType, Lexeme: t.lexeme, Line: t.line, Column: t.column}
+}
+
+func (e astEncoder) VisitAutomatonDefDefinition(definition AutomatonDef) (any, error) {
+	node := jsonNode{
+		Kind: "AutomatonDef",
+		Type: encodeToken(definition.autType),
+		Name: encodeToken(definition.name),
+	}
+	for _, stmt := range definition.stmts {
+		child, err := stmt.Accept(e)
+		if err != nil {
+			return nil, err
+		}
+		node.Stmts = append(node.Stmts, child.(jsonNode))
+	}
+	return node, nil
+}
+
+func (e astEncoder) VisitFunctionDefDefinition(definition FunctionDef) (any, error) {
+	node := jsonNode{
+		Kind:   "FunctionDef",
+		Name:   encodeToken(definition.name),
+		Params: []jsonToken{},
+	}
+	for _, param := range definition.params {
+		node.Params = append(node.Params, *encodeToken(param))
+	}
+	for _, statement := range definition.statements {
+		child, err := statement.Accept(e)
+		if err != nil {
+			return nil, err
+		}
+		node.Statements = append(node.Statements, child.(jsonNode))
+	}
+	return node, nil
+}
+
+func (e astEncoder) VisitStateDeclStmt(stmt StateDecl) (any, error) {
+	return jsonNode{
+		Kind: "StateDecl",
+		Type: encodeToken(stmt.stateType),
+		Name: encodeToken(stmt.name),
+	}, nil
+}
+
+func (e astEncoder) VisitTransDeclStmt(stmt TransDecl) (any, error) {
+	node := jsonNode{
+		Kind: "TransDecl",
+		From: encodeToken(stmt.fromState),
+		To:   encodeToken(stmt.toState),
+	}
+	for _, condition := range stmt.conditions {
+		child, err := condition.Accept(e)
+		if err != nil {
+			return nil, err
+		}
+		node.Conditions = append(node.Conditions, child.(jsonNode))
+	}
+	return node, nil
+}
+
+func (e astEncoder) VisitCallStatement(statement Call) (any, error) {
+	return jsonNode{
+		Kind:   "Call",
+		Target: encodeToken(statement.target),
+		Input:  encodeToken(statement.input),
+	}, nil
+}
+
+func (e astEncoder) VisitStringConditionCondition(condition StringCondition) (any, error) {
+	return jsonNode{Kind: "StringCondition", Value: condition.value}, nil
+}
+
+func (e astEncoder) VisitRegexConditionCondition(condition RegexCondition) (any, error) {
+	return jsonNode{Kind: "RegexCondition", Pattern: condition.pattern}, nil
+}
+
+func decodeToken(t *jsonToken, kind string, field string) (Token, error) {
+	if t == nil {
+		return Token{}, fmt.Errorf("%s is missing field %q", kind, field)
+	}
+	return Token{t.Type, t.Lexeme, t.Line, t.Column}, nil
+}
+
+func decodeDefinition(node jsonNode) (Definition, error) {
+	switch node.Kind {
+	case "AutomatonDef":
+		autType, err := decodeToken(node.Type, node.Kind, "type")
+		if err != nil {
+			return nil, err
+		}
+		name, err := decodeToken(node.Name, node.Kind, "name")
+		if err != nil {
+			return nil, err
+		}
+		var stmts []Stmt
+		for _, child := range node.Stmts {
+			stmt, err := decodeStmt(child)
+			if err != nil {
+				return nil, err
+			}
+			stmts = append(stmts, stmt)
+		}
+		return &AutomatonDef{autType: autType, name: name, stmts: stmts}, nil
+	case "FunctionDef":
+		name, err := decodeToken(node.Name, node.Kind, "name")
+		if err != nil {
+			return nil, err
+		}
+		params := []Token{}
+		for _, param := range node.Params {
+			params = append(params, Token{param.Type, param.Lexeme, param.Line, param.Column})
+		}
+		var statements []Statement
+		for _, child := range node.Statements {
+			statement, err := decodeStatement(child)
+			if err != nil {
+				return nil, err
+			}
+			statements = append(statements, statement)
+		}
+		return &FunctionDef{name: name, params: params, statements: statements}, nil
+	}
+	return nil, fmt.Errorf("unknown definition kind %q", node.Kind)
+}
+
+func decodeStmt(node jsonNode) (Stmt, error) {
+	switch node.Kind {
+	case "StateDecl":
+		stateType, err := decodeToken(node.Type, node.Kind, "type")
+		if err != nil {
+			return nil, err
+		}
+		name, err := decodeToken(node.Name, node.Kind, "name")
+		if err != nil {
+			return nil, err
+		}
+		return &StateDecl{stateType: stateType, name: name}, nil
+	case "TransDecl":
+		from, err := decodeToken(node.From, node.Kind, "from")
+		if err != nil {
+			return nil, err
+		}
+		to, err := decodeToken(node.To, node.Kind, "to")
+		if err != nil {
+			return nil, err
+		}
+		var conditions []Condition
+		for _, child := range node.Conditions {
+			condition, err := decodeCondition(child)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, condition)
+		}
+		return &TransDecl{fromState: from, toState: to, conditions: conditions}, nil
+	}
+	return nil, fmt.Errorf("unknown statement kind %q", node.Kind)
+}
+
+func decodeStatement(node jsonNode) (Statement, error) {
+	if node.Kind != "Call" {
+		return nil, fmt.Errorf("unknown function statement kind %q", node.Kind)
+	}
+	target, err := decodeToken(node.Target, node.Kind, "target")
+	if err != nil {
+		return nil, err
+	}
+	input, err := decodeToken(node.Input, node.Kind, "input")
+	if err != nil {
+		return nil, err
+	}
+	return Call{target: target, input: input}, nil
+}
+
+func decodeCondition(node jsonNode) (Condition, error) {
+	switch node.Kind {
+	case "StringCondition":
+		return StringCondition{value: node.Value}, nil
+	case "RegexCondition":
+		return RegexCondition{pattern: node.Pattern}, nil
+	}
+	return nil, fmt.Errorf("unknown condition kind %q", node.Kind)
+}
@@ -0,0 +1,68 @@
+package stateflow
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestASTJSONRoundTrip(t *testing.T) {
+	source := `dfa counter {
+		initial q0;
+		state q1;
+		final q2;
+
+		on q0 -> q1 when "inc" or /[0-9]/;
+		on q1 -> q2 when "done";
+	}
+
+	fn main(input) {
+		counter <- input;
+	}`
+	parser := Parser{Tokens: getTokens(source)}
+	defs, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	data, err := MarshalAST(defs)
+	if err != nil {
+		t.Fatalf("Expected no error encoding, got: %v", err)
+	}
+
+	decoded, err := UnmarshalAST(data)
+	if err != nil {
+		t.Fatalf("Expected no error decoding, got: %v", err)
+	}
+
+	if !reflect.DeepEqual(defs, decoded) {
+		t.Errorf("Decoded AST differs from parsed AST:\n%s", data)
+	}
+}
+
+func TestASTJSONPositions(t *testing.T) {
+	source := "dfa simple {\n  final q0;\n}"
+	parser := Parser{Tokens: getTokens(source)}
+	defs, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	data, err := MarshalAST(defs)
+	if err != nil {
+		t.Fatalf("Expected no error encoding, got: %v", err)
+	}
+
+	compact := strings.Join(strings.Fields(string(data)), "")
+	expected := `"lexeme":"q0","line":2,"column":9`
+	if !strings.Contains(compact, expected) {
+		t.Errorf("Expected state name with position, got:\n%s", data)
+	}
+}
+
+func TestASTJSONUnknownKind(t *testing.T) {
+	_, err := UnmarshalAST([]byte(`{"version": 1, "definitions": [{"kind": "Macro"}]}`))
+	if err == nil {
+		t.Error("Expected error for unknown definition kind")
+	}
+}
@@ -0,0 +1,236 @@
+package stateflow
+
+import (
+	"slices"
+	"sort"
+)
+
+// point is a position in grid units: x is the layer of a state, left to
+// right, and y its row within the layer
+type point struct {
+	X, Y float64
+}
+
+// graphLayout places the states of an automaton using a layered
+// (Sugiyama-style) layout. Renderers scale grid units to their own.
+type graphLayout struct {
+	position map[string]point
+	// Points an edge bends through between its states, in order from the
+	// source to the target. Edges spanning several layers get one point per
+	// layer in between; self-loops are left to the renderer.
+	bends   map[*graphEdge][]point
+	columns int // Number of layers
+	rows    int // Size of the largest layer
+}
+
+// crossingSweeps is how many down-and-up barycenter passes are tried
+const crossingSweeps = 8
+
+// layoutGraph lays out an automaton in four steps: edges closing a cycle
+// are reversed, states are assigned to layers by longest path from the
+// initial state, edges spanning several layers are split with dummy nodes,
+// and the order of every layer is refined with the barycenter heuristic to
+// reduce crossings.
+func layoutGraph(graph *automatonGraph) *graphLayout {
+	n := len(graph.states)
+	index := make(map[string]int, n)
+	for i, state := range graph.states {
+		index[state.name] = i
+	}
+
+	// Depth-first search from the initial state, then from the rest in
+	// source order; an edge into a state on the stack closes a cycle
+	var edges [][2]int // Acyclic edges, between node indices
+	var edgeOf []*graphEdge
+	reversed := make(map[*graphEdge]bool)
+	outgoing := make([][]*graphEdge, n)
+	for _, edge := range graph.edges {
+		if edge.from != edge.to {
+			outgoing[index[edge.from]] = append(outgoing[index[edge.from]], edge)
+		}
+	}
+	visited := make([]int, n) // 0 unvisited, 1 on the stack, 2 done
+	var visit func(int)
+	visit = func(u int) {
+		visited[u] = 1
+		for _, edge := range outgoing[u] {
+			v := index[edge.to]
+			if visited[v] == 1 {
+				reversed[edge] = true
+				edges = append(edges, [2]int{v, u})
+			} else {
+				edges = append(edges, [2]int{u, v})
+			}
+			edgeOf = append(edgeOf, edge)
+			if visited[v] == 0 {
+				visit(v)
+			}
+		}
+		visited[u] = 2
+	}
+	roots := make([]int, 0, n)
+	for i, state := range graph.states {
+		if state.kind == INITIAL {
+			roots = append(roots, i)
+		}
+	}
+	for i := range graph.states {
+		roots = append(roots, i)
+	}
+	for _, root := range roots {
+		if visited[root] == 0 {
+			visit(root)
+		}
+	}
+
+	// Longest path layering, relaxing edges until nothing changes; the
+	// graph is acyclic so this ends after at most n rounds
+	layer := make([]int, n)
+	for changed := true; changed; {
+		changed = false
+		for _, e := range edges {
+			if layer[e[1]] < layer[e[0]]+1 {
+				layer[e[1]] = layer[e[0]] + 1
+				changed = true
+			}
+		}
+	}
+
+	// Split long edges with dummy nodes, numbered after the states
+	layerOf := slices.Clone(layer)
+	var links [][2]int // Edges between adjacent layers
+	chains := make(map[*graphEdge][]int)
+	for i, e := range edges {
+		u := e[0]
+		for l := layer[e[0]] + 1; l < layer[e[1]]; l++ {
+			dummy := len(layerOf)
+			layerOf = append(layerOf, l)
+			links = append(links, [2]int{u, dummy})
+			chains[edgeOf[i]] = append(chains[edgeOf[i]], dummy)
+			u = dummy
+		}
+		links = append(links, [2]int{u, e[1]})
+	}
+
+	columns := 0
+	for _, l := range layerOf {
+		columns = max(columns, l+1)
+	}
+	layers := make([][]int, columns)
+	for node, l := range layerOf {
+		layers[l] = append(layers[l], node)
+	}
+
+	up := make([][]int, len(layerOf))   // Neighbours in the previous layer
+	down := make([][]int, len(layerOf)) // Neighbours in the next layer
+	for _, link := range links {
+		down[link[0]] = append(down[link[0]], link[1])
+		up[link[1]] = append(up[link[1]], link[0])
+	}
+
+	best := cloneLayers(layers)
+	bestCrossings := countCrossings(layers, down)
+	for sweep := 0; sweep < crossingSweeps && bestCrossings > 0; sweep++ {
+		for l := 1; l < columns; l++ {
+			orderByBarycenter(layers[l], layers[l-1], up)
+		}
+		for l := columns - 2; l >= 0; l-- {
+			orderByBarycenter(layers[l], layers[l+1], down)
+		}
+		if crossings := countCrossings(layers, down); crossings < bestCrossings {
+			best, bestCrossings = cloneLayers(layers), crossings
+		}
+	}
+
+	rows := 0
+	for _, nodes := range best {
+		rows = max(rows, len(nodes))
+	}
+	positions := make([]point, len(layerOf))
+	for l, nodes := range best {
+		// Center each layer against the largest one
+		offset := float64(rows-len(nodes)) / 2
+		for i, node := range nodes {
+			positions[node] = point{float64(l), offset + float64(i)}
+		}
+	}
+
+	layout := &graphLayout{
+		position: make(map[string]point, n),
+		bends:    make(map[*graphEdge][]point),
+		columns:  columns,
+		rows:     rows,
+	}
+	for i, state := range graph.states {
+		layout.position[state.name] = positions[i]
+	}
+	for edge, chain := range chains {
+		var bends []point
+		for _, dummy := range chain {
+			bends = append(bends, positions[dummy])
+		}
+		if reversed[edge] {
+			slices.Reverse(bends)
+		}
+		layout.bends[edge] = bends
+	}
+	return layout
+}
+
+func cloneLayers(layers [][]int) [][]int {
+	clone := make([][]int, len(layers))
+	for i, nodes := range layers {
+		clone[i] = slices.Clone(nodes)
+	}
+	return clone
+}
+
+// Sorts a layer by the mean position of each node's neighbours in a fixed
+// layer. Nodes without neighbours keep their place.
+func orderByBarycenter(nodes []int, fixed []int, neighbours [][]int) {
+	position := make(map[int]int, len(fixed))
+	for i, node := range fixed {
+		position[node] = i
+	}
+	barycenter := make(map[int]float64, len(nodes))
+	for i, node := range nodes {
+		if len(neighbours[node]) == 0 {
+			barycenter[node] = float64(i)
+			continue
+		}
+		sum := 0
+		for _, neighbour := range neighbours[node] {
+			sum += position[neighbour]
+		}
+		barycenter[node] = float64(sum) / float64(len(neighbours[node]))
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return barycenter[nodes[i]] < barycenter[nodes[j]]
+	})
+}
+
+// Counts the pairs of links that cross between every two adjacent layers
+func countCrossings(layers [][]int, down [][]int) int {
+	crossings := 0
+	for l := 0; l+1 < len(layers); l++ {
+		position := make(map[int]int)
+		for i, node := range layers[l+1] {
+			position[node] = i
+		}
+		var links [][2]int
+		for i, node := range layers[l] {
+			for _, next := range down[node] {
+				links = append(links, [2]int{i, position[next]})
+			}
+		}
+		for i := range links {
+			for j := i + 1; j < len(links); j++ {
+				a, b := links[i], links[j]
+				if (a[0]-b[0])*(a[1]-b[1]) < 0 {
+					crossings++
+				}
+			}
+		}
+	}
+	return crossings
+}
@@ -0,0 +1,480 @@
+package stateflow
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Language Server Protocol support. Only the small subset of the protocol
+// used by the server is modelled here.
+
+type lspMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  any              `json:"result,omitempty"`
+	Error   *lspError        `json:"error,omitempty"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspCompletionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type lspTextDocumentPositionParams struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position lspPosition `json:"position"`
+	Context  struct {
+		IncludeDeclaration bool `json:"includeDeclaration"`
+	} `json:"context"`
+	NewName string `json:"newName"`
+}
+
+type lspDidOpenParams struct {
+	TextDocument struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"textDocument"`
+}
+
+type lspDidChangeParams struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+const (
+	lspParseError     = -32700
+	lspMethodNotFound = -32601
+	lspInvalidParams  = -32602
+
+	lspSeverityError = 1
+
+	lspCompletionKeyword  = 14
+	lspCompletionVariable = 6
+	lspCompletionClass    = 7
+	lspCompletionEnum     = 20
+)
+
+// lspDocument is an open text document and what is known about it
+type lspDocument struct {
+	source      []byte
+	lines       []int // Byte offset of the start of every line
+	index       *lspIndex
+	diagnostics []lspDiagnostic
+}
+
+func newLspDocument(text string) *lspDocument {
+	doc := &lspDocument{source: []byte(text), lines: []int{0}}
+	for i, c := range doc.source {
+		if c == '\n' {
+			doc.lines = append(doc.lines, i+1)
+		}
+	}
+	doc.index = newLspIndex(doc.source, doc.check())
+	return doc
+}
+
+// check runs the scanner and the parser exactly like `stateflow parse`
+// does, keeps what they find as diagnostics and returns the parser's
+// symbol table, or nil when the scanner rejects the document
+func (doc *lspDocument) check() *SymbolTable {
+	doc.diagnostics = []lspDiagnostic{}
+	scanner := Scanner{Source: doc.source}
+	tokens, scanErrs := scanner.ScanTokens()
+	if len(scanErrs) > 0 {
+		for _, err := range scanErrs {
+			line := 1
+			message := err.Error()
+			if syntaxErr, ok := err.(SyntaxError); ok {
+				line = syntaxErr.Line
+				message = syntaxErr.Message
+			}
+			doc.diagnostics = append(doc.diagnostics, doc.diagnostic(doc.lineRange(line), message))
+		}
+		return nil
+	}
+
+	parser := Parser{Tokens: tokens}
+	_, err := parser.Parse()
+	if parseErr, ok := err.(ParseError); ok {
+		doc.diagnostics = append(doc.diagnostics, doc.diagnostic(doc.tokenRange(*parseErr.Token), parseErr.Message))
+	} else if err != nil {
+		doc.diagnostics = append(doc.diagnostics, doc.diagnostic(doc.lineRange(1), err.Error()))
+	}
+	return parser.SymbolTable
+}
+
+func (doc *lspDocument) diagnostic(r lspRange, message string) lspDiagnostic {
+	return lspDiagnostic{Range: r, Severity: lspSeverityError, Source: "stateflow", Message: message}
+}
+
+func (doc *lspDocument) lineText(line int) string {
+	if line < 0 || line >= len(doc.lines) {
+		return ""
+	}
+	end := len(doc.source)
+	if line+1 < len(doc.lines) {
+		end = doc.lines[line+1] - 1
+	}
+	return string(doc.source[doc.lines[line]:end])
+}
+
+// Converts a 0-based line and byte column into a position, whose
+// character is counted in UTF-16 code units
+func (doc *lspDocument) position(line int, column int) lspPosition {
+	text := doc.lineText(line)
+	column = min(max(column, 0), len(text))
+	return lspPosition{Line: line, Character: len(utf16.Encode([]rune(text[:column])))}
+}
+
+// Converts a position into a byte offset in the source
+func (doc *lspDocument) offset(pos lspPosition) int {
+	if pos.Line >= len(doc.lines) {
+		return len(doc.source)
+	}
+	text := doc.lineText(pos.Line)
+	units := 0
+	column := 0
+	for column < len(text) && units < pos.Character {
+		r, size := utf8.DecodeRuneInString(text[column:])
+		units += len(utf16.Encode([]rune{r}))
+		column += size
+	}
+	return doc.lines[pos.Line] + column
+}
+
+func (doc *lspDocument) lineRange(line int) lspRange {
+	return lspRange{doc.position(line-1, 0), doc.position(line-1, len(doc.lineText(line-1)))}
+}
+
+func (doc *lspDocument) tokenRange(token Token) lspRange {
+	start := doc.position(token.line-1, token.column-1)
+	end := doc.position(token.line-1, token.column-1+len(token.lexeme))
+	return lspRange{start, end}
+}
+
+func (doc *lspDocument) cstRange(token *CSTToken) lspRange {
+	return doc.tokenRange(Token{token.Token.tokenType, token.Text(), token.Token.line, token.Token.column})
+}
+
+type lspServer struct {
+	in        *bufio.Reader
+	out       io.Writer
+	documents map[string]*lspDocument
+}
+
+// ServeLSP runs a language server speaking the Language Server Protocol
+// over the given streams until the client sends `exit` or closes the input
+func ServeLSP(in io.Reader, out io.Writer) error {
+	server := &lspServer{
+		in:        bufio.NewReader(in),
+		out:       out,
+		documents: make(map[string]*lspDocument),
+	}
+	for {
+		msg, err := server.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if _, ok := err.(*json.SyntaxError); ok {
+				server.reply(nil, nil, &lspError{lspParseError, err.Error()})
+				continue
+			}
+			return err
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		server.handle(msg)
+	}
+}
+
+func (s *lspServer) read() (*lspMessage, error) {
+	headers, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	length, err := strconv.Atoi(headers.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length header: %v", err)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, err
+	}
+	var msg lspMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (s *lspServer) write(msg lspMessage) {
+	msg.JSONRPC = "2.0"
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(msg)
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", body.Len(), body.Bytes())
+}
+
+func (s *lspServer) reply(id *json.RawMessage, result any, err *lspError) {
+	if id == nil {
+		id = &json.RawMessage{'n', 'u', 'l', 'l'}
+	}
+	if result == nil && err == nil {
+		// The protocol requires the result member on success
+		s.write(lspMessage{ID: id, Result: json.RawMessage("null")})
+		return
+	}
+	s.write(lspMessage{ID: id, Result: result, Error: err})
+}
+
+func (s *lspServer) notify(method string, params any) {
+	data, _ := json.Marshal(params)
+	s.write(lspMessage{Method: method, Params: data})
+}
+
+func (s *lspServer) handle(msg *lspMessage) {
+	var result any
+	var err *lspError
+
+	switch msg.Method {
+	case "initialize":
+		result = map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":   1, // Full
+				"definitionProvider": true,
+				"referencesProvider": true,
+				"hoverProvider":      true,
+				"renameProvider":     true,
+				"completionProvider": map[string]any{
+					"triggerCharacters": []string{">", "-", " "},
+				},
+			},
+			"serverInfo": map[string]string{"name": "stateflow"},
+		}
+	case "initialized", "shutdown":
+	case "textDocument/didOpen":
+		var params lspDidOpenParams
+		if json.Unmarshal(msg.Params, &params) == nil {
+			s.open(params.TextDocument.URI, params.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		var params lspDidChangeParams
+		if json.Unmarshal(msg.Params, &params) == nil && len(params.ContentChanges) > 0 {
+			changes := params.ContentChanges
+			s.open(params.TextDocument.URI, changes[len(changes)-1].Text)
+		}
+	case "textDocument/didClose":
+		var params lspDidOpenParams
+		if json.Unmarshal(msg.Params, &params) == nil {
+			delete(s.documents, params.TextDocument.URI)
+			s.notify("textDocument/publishDiagnostics", map[string]any{
+				"uri":         params.TextDocument.URI,
+				"diagnostics": []lspDiagnostic{},
+			})
+		}
+	case "textDocument/definition", "textDocument/references", "textDocument/hover",
+		"textDocument/completion", "textDocument/rename":
+		var params lspTextDocumentPositionParams
+		if e := json.Unmarshal(msg.Params, &params); e != nil {
+			err = &lspError{lspInvalidParams, e.Error()}
+			break
+		}
+		doc, ok := s.documents[params.TextDocument.URI]
+		if !ok {
+			break
+		}
+		uri := params.TextDocument.URI
+		offset := doc.offset(params.Position)
+		switch msg.Method {
+		case "textDocument/definition":
+			result = s.definition(uri, doc, offset)
+		case "textDocument/references":
+			result = s.references(uri, doc, offset, params.Context.IncludeDeclaration)
+		case "textDocument/hover":
+			result = s.hover(doc, offset)
+		case "textDocument/completion":
+			result = s.completion(doc, offset)
+		case "textDocument/rename":
+			result, err = s.rename(uri, doc, offset, params.NewName)
+		}
+	default:
+		if msg.ID != nil {
+			err = &lspError{lspMethodNotFound, "method not supported: " + msg.Method}
+		}
+	}
+
+	if msg.ID != nil {
+		s.reply(msg.ID, result, err)
+	}
+}
+
+func (s *lspServer) open(uri string, text string) {
+	doc := newLspDocument(text)
+	s.documents[uri] = doc
+	s.notify("textDocument/publishDiagnostics", map[string]any{
+		"uri":         uri,
+		"diagnostics": doc.diagnostics,
+	})
+}
+
+func (s *lspServer) definition(uri string, doc *lspDocument, offset int) any {
+	symbol := doc.index.symbolAt(offset)
+	if symbol == nil {
+		return nil
+	}
+	return lspLocation{uri, doc.tokenRange(*symbol.Token)}
+}
+
+func (s *lspServer) references(uri string, doc *lspDocument, offset int, includeDeclaration bool) any {
+	symbol := doc.index.symbolAt(offset)
+	if symbol == nil {
+		return nil
+	}
+	locations := []lspLocation{}
+	if includeDeclaration {
+		locations = append(locations, lspLocation{uri, doc.tokenRange(*symbol.Token)})
+	}
+	for _, ref := range doc.index.table.References(symbol) {
+		locations = append(locations, lspLocation{uri, doc.tokenRange(*ref)})
+	}
+	return locations
+}
+
+func (s *lspServer) hover(doc *lspDocument, offset int) any {
+	token := doc.index.tokenAt(offset)
+	symbol := doc.index.symbolOf(token)
+	if symbol == nil {
+		return nil
+	}
+	return map[string]any{
+		"contents": map[string]string{"kind": "markdown", "value": doc.index.hover(symbol)},
+		"range":    doc.cstRange(token),
+	}
+}
+
+func (s *lspServer) completion(doc *lspDocument, offset int) any {
+	items := []lspCompletionItem{}
+	definition := doc.index.definitionAt(offset)
+	previous := doc.index.previousToken(offset)
+	// When the cursor is on an identifier, complete what comes before it
+	if previous != nil && previous.Token.tokenType == IDENTIFIER && previous.Offset+len(previous.Text()) == offset {
+		previous = doc.index.previousToken(previous.Offset)
+	}
+	previousType := TokenType("")
+	if previous != nil {
+		previousType = previous.Token.tokenType
+	}
+
+	keywords := func(types ...TokenType) {
+		for _, keyword := range keywordsOf(types...) {
+			items = append(items, lspCompletionItem{keyword, lspCompletionKeyword, ""})
+		}
+	}
+	automata := func() {
+		if doc.index.table != nil {
+			for _, symbol := range doc.index.table.Symbols(SymbolAutomaton) {
+				items = append(items, lspCompletionItem{symbol.Name, lspCompletionClass, "automaton"})
+			}
+		}
+	}
+
+	switch {
+	case definition != nil && definition.node.Kind == NodeAutomatonDef && (previousType == ON || previousType == ARROW_RIGHT):
+		for _, state := range doc.index.locals(definition.symbol) {
+			stateType, _ := state.Metadata["stateType"].(TokenType)
+			items = append(items, lspCompletionItem{state.Name, lspCompletionEnum, strings.ToLower(string(stateType)) + " state"})
+		}
+	case definition != nil && definition.node.Kind == NodeAutomatonDef && slices.Contains([]TokenType{LEFT_BRACE, SEMICOLON, RIGHT_BRACE}, previousType):
+		keywords(INITIAL, STATE, FINAL, ON)
+	case definition != nil && definition.node.Kind == NodeFunctionDef && previousType == ARROW_LEFT:
+		for _, param := range doc.index.locals(definition.symbol) {
+			items = append(items, lspCompletionItem{param.Name, lspCompletionVariable, "parameter"})
+		}
+		automata()
+	case definition != nil && definition.node.Kind == NodeFunctionDef && (previousType == LEFT_BRACE || previousType == SEMICOLON):
+		automata()
+	case definition == nil || previousType == RIGHT_BRACE || previous == nil:
+		keywords(DFA, NFA, FUNCTION)
+	}
+	slices.SortFunc(items, func(a, b lspCompletionItem) int {
+		return strings.Compare(a.Label, b.Label)
+	})
+	return items
+}
+
+func (s *lspServer) rename(uri string, doc *lspDocument, offset int, newName string) (any, *lspError) {
+	symbol := doc.index.symbolAt(offset)
+	if symbol == nil {
+		return nil, nil
+	}
+	if newName == "" || !isAlpha(newName[0]) || strings.IndexFunc(newName, func(r rune) bool {
+		return r >= utf8.RuneSelf || !isAlphanumeric(byte(r))
+	}) >= 0 {
+		return nil, &lspError{lspInvalidParams, "'" + newName + "' is not a valid identifier."}
+	}
+	if _, isKeyword := keywords[newName]; isKeyword {
+		return nil, &lspError{lspInvalidParams, "'" + newName + "' is a keyword."}
+	}
+
+	edits := []lspTextEdit{{doc.tokenRange(*symbol.Token), newName}}
+	for _, ref := range doc.index.table.References(symbol) {
+		edits = append(edits, lspTextEdit{doc.tokenRange(*ref), newName})
+	}
+	return map[string]any{"changes": map[string][]lspTextEdit{uri: edits}}, nil
+}
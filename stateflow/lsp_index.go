@@ -0,0 +1,183 @@
+package stateflow
+
+import (
+	"slices"
+	"strings"
+)
+
+// lspDefinition is an automaton or function definition of the document
+type lspDefinition struct {
+	node       *CSTNode
+	symbol     *Symbol // Nil when the parser stopped before declaring it
+	start, end int     // Byte offsets covered by the definition
+}
+
+// lspIndex finds what is under the cursor. Positions come from the
+// concrete syntax tree, which holds every token even while the file does
+// not parse, and names are resolved by the parser's symbol table, so
+// navigation agrees with `stateflow parse` up to its first error.
+type lspIndex struct {
+	tokens      []*CSTToken
+	definitions []*lspDefinition
+	table       *SymbolTable // Nil when the scanner rejects the document
+}
+
+func newLspIndex(source []byte, table *SymbolTable) *lspIndex {
+	root, _ := ParseCST(source)
+	index := &lspIndex{tokens: root.Tokens(), table: table}
+	for _, node := range root.Nodes() {
+		tokens := node.Tokens()
+		last := tokens[len(tokens)-1]
+		definition := &lspDefinition{
+			node:  node,
+			start: tokens[0].Offset,
+			end:   last.Offset + len(last.Text()),
+		}
+		// The name of a definition the parser read is the token of its symbol
+		if len(tokens) > 1 {
+			if symbol := index.symbolOf(tokens[1]); symbol != nil && sameToken(symbol.Token, tokens[1]) {
+				definition.symbol = symbol
+			}
+		}
+		index.definitions = append(index.definitions, definition)
+	}
+	return index
+}
+
+// symbolOf returns the symbol the token declares or refers to
+func (index *lspIndex) symbolOf(token *CSTToken) *Symbol {
+	if index.table == nil || token == nil || token.Token.tokenType != IDENTIFIER {
+		return nil
+	}
+	for _, symbol := range index.table.Declared() {
+		if sameToken(symbol.Token, token) || slices.ContainsFunc(index.table.References(symbol), func(ref *Token) bool {
+			return sameToken(ref, token)
+		}) {
+			return symbol
+		}
+	}
+	return nil
+}
+
+// Reports whether a token of the parser is the token of the syntax tree
+func sameToken(t *Token, token *CSTToken) bool {
+	return t.line == token.Token.line && t.column == token.Token.column
+}
+
+// locals returns the states of an automaton or the parameters of a
+// function, in declaration order
+func (index *lspIndex) locals(owner *Symbol) []*Symbol {
+	if index.table == nil || owner == nil {
+		return nil
+	}
+	var locals []*Symbol
+	for _, symbol := range index.table.Declared() {
+		if symbol.Scope == owner {
+			locals = append(locals, symbol)
+		}
+	}
+	return locals
+}
+
+// tokenAt returns the significant token under or right before the offset
+func (index *lspIndex) tokenAt(offset int) *CSTToken {
+	for _, token := range index.tokens {
+		if token.Inserted || token.Token.tokenType == BOF || token.Token.tokenType == EOF {
+			continue
+		}
+		if token.Offset <= offset && offset <= token.Offset+len(token.Text()) {
+			return token
+		}
+	}
+	return nil
+}
+
+func (index *lspIndex) symbolAt(offset int) *Symbol {
+	return index.symbolOf(index.tokenAt(offset))
+}
+
+// definitionAt returns the definition containing the offset
+func (index *lspIndex) definitionAt(offset int) *lspDefinition {
+	for _, definition := range index.definitions {
+		if definition.start <= offset && offset <= definition.end {
+			return definition
+		}
+	}
+	return nil
+}
+
+// previousToken returns the last written token that ends before the offset
+func (index *lspIndex) previousToken(offset int) *CSTToken {
+	var previous *CSTToken
+	for _, token := range index.tokens {
+		if token.Inserted || token.Token.tokenType == BOF || token.Token.tokenType == EOF {
+			continue
+		}
+		if token.Offset+len(token.Text()) > offset {
+			break
+		}
+		previous = token
+	}
+	return previous
+}
+
+// hover describes the symbol in markdown
+func (index *lspIndex) hover(symbol *Symbol) string {
+	var b strings.Builder
+	switch symbol.Type {
+	case SymbolState:
+		stateType, _ := symbol.Metadata["stateType"].(TokenType)
+		b.WriteString("**" + strings.ToLower(string(stateType)) + "** state `" + symbol.Name + "`")
+		var outgoing []string
+		if owner := symbol.Scope; owner != nil {
+			automatonType, _ := owner.Metadata["automatonType"].(TokenType)
+			b.WriteString(" of " + strings.ToLower(string(automatonType)) + " `" + owner.Name + "`")
+			transitions, _ := owner.Metadata["transitions"].([]*TransDecl)
+			for _, transition := range transitions {
+				if transition.fromState.lexeme != symbol.Name {
+					continue
+				}
+				var conditions []string
+				for _, condition := range transition.conditions {
+					conditions = append(conditions, conditionText(condition))
+				}
+				outgoing = append(outgoing, "- `"+symbol.Name+" -> "+transition.toState.lexeme+"` when "+
+					strings.Join(conditions, " or "))
+			}
+		}
+		if len(outgoing) == 0 {
+			b.WriteString("\n\nNo outgoing transitions.")
+		} else {
+			b.WriteString("\n\nOutgoing transitions:\n" + strings.Join(outgoing, "\n"))
+		}
+	case SymbolAutomaton:
+		automatonType, _ := symbol.Metadata["automatonType"].(TokenType)
+		b.WriteString("**" + strings.ToLower(string(automatonType)) + "** `" + symbol.Name + "`")
+		var states []string
+		for _, state := range index.locals(symbol) {
+			states = append(states, state.Name)
+		}
+		if len(states) > 0 {
+			b.WriteString("\n\nStates: `" + strings.Join(states, "`, `") + "`")
+		}
+	case SymbolFunction:
+		b.WriteString("**fn** `" + symbol.Name + "`")
+	case SymbolParam:
+		b.WriteString("parameter `" + symbol.Name + "`")
+		if symbol.Scope != nil {
+			b.WriteString(" of fn `" + symbol.Scope.Name + "`")
+		}
+	}
+	return b.String()
+}
+
+// keywordsOf returns the keywords the scanner reads as the given token types
+func keywordsOf(types ...TokenType) []string {
+	var words []string
+	for word, tokenType := range keywords {
+		if slices.Contains(types, tokenType) {
+			words = append(words, word)
+		}
+	}
+	return words
+}
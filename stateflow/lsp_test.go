@@ -0,0 +1,208 @@
+package stateflow
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const lspTestSource = `dfa counter {
+  initial q0;
+  state q1;
+  final q2;
+
+  on q0 -> q1 when "inc";
+  on q1 -> q2 when "inc";
+}
+
+fn main(input) {
+  counter <- input;
+}
+`
+
+// Sends the requests to a server and returns its responses by id, along
+// with the notifications it published
+func lspSession(t *testing.T, requests []map[string]any) (map[int]json.RawMessage, []map[string]any) {
+	var in strings.Builder
+	for _, request := range requests {
+		request["jsonrpc"] = "2.0"
+		body, _ := json.Marshal(request)
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		ServeLSP(strings.NewReader(in.String()), writer)
+		writer.Close()
+	}()
+
+	responses := make(map[int]json.RawMessage)
+	var notifications []map[string]any
+	out := bufio.NewReader(reader)
+	for {
+		headers, err := textproto.NewReader(out).ReadMIMEHeader()
+		if err != nil {
+			break
+		}
+		length, _ := strconv.Atoi(headers.Get("Content-Length"))
+		body := make([]byte, length)
+		if _, err := io.ReadFull(out, body); err != nil {
+			t.Fatal(err)
+		}
+		var msg struct {
+			ID     *int            `json:"id"`
+			Method string          `json:"method"`
+			Params map[string]any  `json:"params"`
+			Result json.RawMessage `json:"result"`
+		}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.ID != nil {
+			responses[*msg.ID] = msg.Result
+		} else {
+			notifications = append(notifications, msg.Params)
+		}
+	}
+	return responses, notifications
+}
+
+func positionRequest(id int, method string, line, character int, extra map[string]any) map[string]any {
+	params := map[string]any{
+		"textDocument": map[string]any{"uri": "file:///counter.sf"},
+		"position":     map[string]any{"line": line, "character": character},
+	}
+	for key, value := range extra {
+		params[key] = value
+	}
+	return map[string]any{"id": id, "method": method, "params": params}
+}
+
+func TestLSPNavigation(t *testing.T) {
+	responses, notifications := lspSession(t, []map[string]any{
+		{"id": 1, "method": "initialize", "params": map[string]any{}},
+		{"method": "textDocument/didOpen", "params": map[string]any{
+			"textDocument": map[string]any{"uri": "file:///counter.sf", "text": lspTestSource},
+		}},
+		positionRequest(2, "textDocument/definition", 5, 12, nil),
+		positionRequest(3, "textDocument/references", 2, 9, map[string]any{"context": map[string]any{"includeDeclaration": true}}),
+		positionRequest(4, "textDocument/hover", 5, 6, nil),
+		positionRequest(5, "textDocument/rename", 10, 4, map[string]any{"newName": "count"}),
+		{"id": 6, "method": "shutdown"},
+		{"method": "exit"},
+	})
+
+	if len(notifications) != 1 || len(notifications[0]["diagnostics"].([]any)) != 0 {
+		t.Errorf("Expected empty diagnostics, got %v", notifications)
+	}
+
+	// `q1` in `on q0 -> q1` points at `state q1;`
+	expected := `{"uri":"file:///counter.sf","range":{"start":{"line":2,"character":8},"end":{"line":2,"character":10}}}`
+	if string(responses[2]) != expected {
+		t.Errorf("Unexpected definition: %s", responses[2])
+	}
+
+	var references []lspLocation
+	json.Unmarshal(responses[3], &references)
+	if len(references) != 3 {
+		t.Errorf("Expected 3 references to q1, got %s", responses[3])
+	}
+
+	if !strings.Contains(string(responses[4]), "**initial** state `q0` of dfa `counter`") ||
+		!strings.Contains(string(responses[4]), "`q0 -> q1` when \\\"inc\\\"") {
+		t.Errorf("Unexpected hover: %s", responses[4])
+	}
+
+	var rename struct {
+		Changes map[string][]lspTextEdit `json:"changes"`
+	}
+	json.Unmarshal(responses[5], &rename)
+	if edits := rename.Changes["file:///counter.sf"]; len(edits) != 2 || edits[0].Range.Start.Line != 0 || edits[1].Range.Start.Line != 10 {
+		t.Errorf("Unexpected rename edits: %s", responses[5])
+	}
+
+	if string(responses[6]) != "null" {
+		t.Errorf("Expected null shutdown result, got %s", responses[6])
+	}
+}
+
+func TestLSPDiagnosticsAndCompletion(t *testing.T) {
+	source := strings.Replace(lspTestSource, "on q1 -> q2", "on q1 -> q3", 1)
+	responses, notifications := lspSession(t, []map[string]any{
+		{"method": "textDocument/didOpen", "params": map[string]any{
+			"textDocument": map[string]any{"uri": "file:///counter.sf", "text": source},
+		}},
+		positionRequest(1, "textDocument/completion", 6, 11, nil),
+		// The parser stops at the error, before main, so complete its
+		// parameters once the error is fixed
+		{"method": "textDocument/didChange", "params": map[string]any{
+			"textDocument":   map[string]any{"uri": "file:///counter.sf"},
+			"contentChanges": []map[string]any{{"text": lspTestSource}},
+		}},
+		positionRequest(2, "textDocument/completion", 10, 13, nil),
+	})
+
+	diagnostics := notifications[0]["diagnostics"].([]any)
+	if len(diagnostics) != 1 || !strings.Contains(fmt.Sprint(diagnostics[0]), "undefined state 'q3'") {
+		t.Errorf("Expected undefined state diagnostic, got %v", diagnostics)
+	}
+
+	var states []lspCompletionItem
+	json.Unmarshal(responses[1], &states)
+	if len(states) != 3 || states[0].Label != "q0" || states[2].Label != "q2" {
+		t.Errorf("Expected the three states, got %s", responses[1])
+	}
+
+	var inputs []lspCompletionItem
+	json.Unmarshal(responses[2], &inputs)
+	if len(inputs) != 2 || inputs[0].Label != "counter" || inputs[1].Label != "input" {
+		t.Errorf("Expected automaton and parameter, got %s", responses[2])
+	}
+}
+
+func TestLSPResolvesLikeTheParser(t *testing.T) {
+	source := `fn main(input) {
+  second <- input;
+}
+
+dfa first {
+  initial q0;
+  on q0 -> q0 when "a";
+}
+
+dfa second {
+  initial q0;
+  on q0 -> q0 when "b";
+}
+`
+	responses, _ := lspSession(t, []map[string]any{
+		{"method": "textDocument/didOpen", "params": map[string]any{
+			"textDocument": map[string]any{"uri": "file:///counter.sf", "text": source},
+		}},
+		positionRequest(1, "textDocument/references", 11, 5, map[string]any{"context": map[string]any{"includeDeclaration": true}}),
+		positionRequest(2, "textDocument/definition", 1, 3, nil),
+	})
+
+	// Each automaton has its own q0
+	var references []lspLocation
+	json.Unmarshal(responses[1], &references)
+	if len(references) != 3 {
+		t.Fatalf("Expected the 3 occurrences of the second q0, got %s", responses[1])
+	}
+	for _, reference := range references {
+		if reference.Range.Start.Line < 9 {
+			t.Errorf("Expected no occurrence of the first q0, got %s", responses[1])
+		}
+	}
+
+	// Calls resolve automata defined after the function
+	expected := `{"uri":"file:///counter.sf","range":{"start":{"line":9,"character":4},"end":{"line":9,"character":10}}}`
+	if string(responses[2]) != expected {
+		t.Errorf("Unexpected definition: %s", responses[2])
+	}
+}
@@ -1,7 +1,9 @@
 package stateflow
 
 import (
+	"maps"
 	"slices"
+	"strings"
 )
 
 // Symbol represents an entry in the symbol table
@@ -10,6 +12,7 @@ type Symbol struct {
 	Type     SymbolType
 	Token    *Token
 	Metadata map[string]any // For storing additional info (params, states, etc.)
+	Scope    *Symbol        // Automaton or function declaring the symbol, nil for globals
 }
 
 type SymbolType string
@@ -23,7 +26,9 @@ const (
 
 // SymbolTable manages symbol declarations and scoping
 type SymbolTable struct {
-	scopes []map[string]*Symbol // Stack of scopes
+	scopes     []map[string]*Symbol // Stack of scopes
+	declared   []*Symbol            // Every symbol defined, including those of closed scopes
+	references map[*Token]*Symbol   // Symbol each resolved identifier refers to
 }
 
 func NewSymbolTable() *SymbolTable {
@@ -38,6 +43,7 @@ func (st *SymbolTable) Define(name string, symbol *Symbol) error {
 		return ParseError{symbol.Token, "Symbol '" + name + "' already defined in this scope."}
 	}
 	current[name] = symbol
+	st.declared = append(st.declared, symbol)
 	return nil
 }
 
@@ -51,6 +57,74 @@ func (st *SymbolTable) Lookup(name string) *Symbol {
 	return nil
 }
 
+// Resolve looks up the name of the token and, when it is defined, records
+// the token as a reference to the symbol
+func (st *SymbolTable) Resolve(token *Token) *Symbol {
+	sym := st.Lookup(token.lexeme)
+	if sym != nil {
+		if st.references == nil {
+			st.references = make(map[*Token]*Symbol)
+		}
+		st.references[token] = sym
+	}
+	return sym
+}
+
+// Declared returns every symbol defined so far in declaration order,
+// including the states and parameters of scopes that have been popped
+func (st *SymbolTable) Declared() []*Symbol {
+	return st.declared
+}
+
+// References returns the tokens resolved to the symbol, in source order
+func (st *SymbolTable) References(symbol *Symbol) []*Token {
+	var tokens []*Token
+	for token, sym := range st.references {
+		if sym == symbol {
+			tokens = append(tokens, token)
+		}
+	}
+	slices.SortFunc(tokens, func(a, b *Token) int {
+		if a.line != b.line {
+			return a.line - b.line
+		}
+		return a.column - b.column
+	})
+	return tokens
+}
+
+// Symbols returns the visible symbols of the given type, sorted by name.
+// Inner declarations shadow outer ones with the same name.
+func (st *SymbolTable) Symbols(symbolType SymbolType) []*Symbol {
+	seen := make(map[string]bool)
+	var symbols []*Symbol
+	for i := len(st.scopes) - 1; i >= 0; i-- {
+		for name, sym := range st.scopes[i] {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			if sym.Type == symbolType {
+				symbols = append(symbols, sym)
+			}
+		}
+	}
+	slices.SortFunc(symbols, func(a, b *Symbol) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return symbols
+}
+
+// Clone returns a copy of the table that can be extended without
+// affecting the original
+func (st *SymbolTable) Clone() *SymbolTable {
+	clone := &SymbolTable{declared: slices.Clone(st.declared), references: maps.Clone(st.references)}
+	for _, scope := range st.scopes {
+		clone.scopes = append(clone.scopes, maps.Clone(scope))
+	}
+	return clone
+}
+
 func (st *SymbolTable) PushScope() {
 	st.scopes = append(st.scopes, make(map[string]*Symbol))
 }
@@ -65,12 +139,22 @@ type Parser struct {
 	Tokens      []Token
 	current     int
 	SymbolTable *SymbolTable
+	scope       *Symbol  // Automaton or function whose body is being parsed
+	calls       []*Token // Automata named by call statements
 }
 
 func (p *Parser) Parse() ([]Definition, error) {
 	if p.SymbolTable == nil {
 		p.SymbolTable = NewSymbolTable()
 	}
+	// Calls may name automata defined after them, so they are resolved
+	// once every definition has been read, or as many as there are
+	// before an error
+	defer func() {
+		for _, target := range p.calls {
+			p.SymbolTable.Resolve(target)
+		}
+	}()
 
 	if !p.match(BOF) {
 		return nil, ParseError{p.peek(), "Expect BOF at start of program."}
@@ -152,14 +236,15 @@ func (p *Parser) automatonDef() (*AutomatonDef, error) {
 		return nil, err
 	}
 
-	if err := p.SymbolTable.Define(name.lexeme, &Symbol{
+	symbol := &Symbol{
 		Name:  name.lexeme,
 		Type:  SymbolAutomaton,
 		Token: name,
 		Metadata: map[string]any{
 			"automatonType": automatonType.tokenType,
 		},
-	}); err != nil {
+	}
+	if err := p.SymbolTable.Define(name.lexeme, symbol); err != nil {
 		return nil, err
 	}
 
@@ -168,7 +253,15 @@ func (p *Parser) automatonDef() (*AutomatonDef, error) {
 		return nil, err
 	}
 
+	// States are local to their automaton
+	p.SymbolTable.PushScope()
+	p.scope = symbol
 	stmts, err := p.stmtList()
+	if err == nil {
+		p.resolveTransitions(symbol, stmts)
+	}
+	p.scope = nil
+	p.SymbolTable.PopScope()
 	if err != nil {
 		return nil, err
 	}
@@ -189,6 +282,20 @@ func (p *Parser) automatonDef() (*AutomatonDef, error) {
 	}, nil
 }
 
+// Transitions may name states declared after them, so their states are
+// resolved once the whole body has been read
+func (p *Parser) resolveTransitions(automaton *Symbol, stmts []Stmt) {
+	var transitions []*TransDecl
+	for _, stmt := range stmts {
+		if transDecl, ok := stmt.(*TransDecl); ok {
+			p.SymbolTable.Resolve(&transDecl.fromState)
+			p.SymbolTable.Resolve(&transDecl.toState)
+			transitions = append(transitions, transDecl)
+		}
+	}
+	automaton.Metadata["transitions"] = transitions
+}
+
 // validateAutomaton checks various constraints on the automaton
 func (p *Parser) validateAutomaton(stmts []Stmt, automatonType TokenType) error {
 	// Check that at least one state is declared
@@ -482,6 +589,10 @@ func (p *Parser) stateDecl() (*StateDecl, error) {
 		Name:  name.lexeme,
 		Type:  SymbolState,
 		Token: name,
+		Metadata: map[string]any{
+			"stateType": declType.tokenType,
+		},
+		Scope: p.scope,
 	}); err != nil {
 		return nil, err
 	}
@@ -615,6 +726,7 @@ func (p *Parser) functionDef() (*FunctionDef, error) {
 	}
 
 	p.SymbolTable.PushScope()
+	funcSym := p.SymbolTable.Lookup(name.lexeme)
 
 	paramNames := []string{}
 	for _, param := range params {
@@ -622,6 +734,7 @@ func (p *Parser) functionDef() (*FunctionDef, error) {
 			Name:  param.lexeme,
 			Type:  SymbolParam,
 			Token: &param,
+			Scope: funcSym,
 		}); err != nil {
 			p.SymbolTable.PopScope()
 			return nil, err
@@ -629,7 +742,6 @@ func (p *Parser) functionDef() (*FunctionDef, error) {
 		paramNames = append(paramNames, param.lexeme)
 	}
 
-	funcSym := p.SymbolTable.Lookup(name.lexeme)
 	if funcSym != nil {
 		funcSym.Metadata["params"] = paramNames
 	}
@@ -726,8 +838,10 @@ func (p *Parser) statement() (Statement, error) {
 		return Call{}, err
 	}
 
+	p.calls = append(p.calls, target)
+
 	// Validate that source identifier is a declared parameter or variable
-	if p.SymbolTable.Lookup(source.lexeme) == nil {
+	if p.SymbolTable.Resolve(source) == nil {
 		return Call{}, ParseError{
 			source,
 			"Undefined variable or parameter '" + source.lexeme + "'. " +
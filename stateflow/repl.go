@@ -0,0 +1,295 @@
+package stateflow
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const replHelp = `Paste automaton or function definitions to define them. Any other line
+is an input for the current automaton; quote it to keep spaces or to
+enter the empty string ("").
+
+Commands:
+  :load <file>       define everything in a .sf file
+  :use <name>        send inputs to another automaton
+  :states [name]     list the states and transitions of an automaton
+  :list              list defined automata and functions
+  :run <fn> <arg>... call a function and show every automaton it runs
+  :trace on|off      show a table of the transitions taken for each symbol
+  :cancel            discard a definition being typed
+  :help              show this help
+  :quit              leave the REPL
+`
+
+// REPL is an interactive session where definitions accumulate and inputs
+// are run against them. The symbol table persists across entries, so the
+// same names are rejected as in a single file.
+type REPL struct {
+	out         io.Writer
+	table       *SymbolTable
+	interpreter *Interpreter
+	current     string // Automaton that receives inputs
+	trace       bool
+	pending     strings.Builder // Definition spanning several lines
+}
+
+func NewREPL(out io.Writer) *REPL {
+	return &REPL{
+		out:         out,
+		table:       NewSymbolTable(),
+		interpreter: NewInterpreter(),
+	}
+}
+
+// Run reads lines until the input ends or the user quits
+func (r *REPL) Run(in io.Reader) error {
+	lines := bufio.NewScanner(in)
+	r.prompt()
+	for lines.Scan() {
+		if !r.Eval(lines.Text()) {
+			return nil
+		}
+		r.prompt()
+	}
+	fmt.Fprintln(r.out)
+	return lines.Err()
+}
+
+func (r *REPL) prompt() {
+	if r.pending.Len() > 0 {
+		fmt.Fprint(r.out, "... ")
+	} else {
+		fmt.Fprint(r.out, "sf> ")
+	}
+}
+
+// Eval handles one line of input and reports whether the session goes on
+func (r *REPL) Eval(line string) bool {
+	line = strings.TrimRight(line, "\r")
+	trimmed := strings.TrimSpace(line)
+
+	if strings.HasPrefix(trimmed, ":") {
+		return r.command(trimmed)
+	}
+
+	if r.pending.Len() == 0 {
+		first := strings.SplitN(trimmed, " ", 2)[0]
+		switch {
+		case trimmed == "":
+			return true
+		case keywords[first] == FUNCTION || keywords[first] == DFA || keywords[first] == NFA ||
+			strings.HasPrefix(first, "dfa{") || strings.HasPrefix(first, "nfa{"):
+		default:
+			r.input(trimmed)
+			return true
+		}
+	}
+
+	r.pending.WriteString(line + "\n")
+	if source := r.pending.String(); complete(source) {
+		r.pending.Reset()
+		r.define([]byte(source))
+	}
+	return true
+}
+
+// Reports whether every brace opened in the source has been closed
+func complete(source string) bool {
+	scanner := Scanner{Source: []byte(source)}
+	tokens, _ := scanner.ScanTokens()
+	depth, opened := 0, false
+	for _, token := range tokens {
+		switch token.tokenType {
+		case LEFT_BRACE:
+			depth++
+			opened = true
+		case RIGHT_BRACE:
+			depth--
+		}
+	}
+	return opened && depth <= 0
+}
+
+func (r *REPL) define(source []byte) {
+	scanner := Scanner{Source: source}
+	tokens, scanErrs := scanner.ScanTokens()
+	if len(scanErrs) > 0 {
+		for _, err := range scanErrs {
+			fmt.Fprint(r.out, err.Error())
+		}
+		return
+	}
+
+	// Parse against a copy so a rejected entry leaves no names behind
+	table := r.table.Clone()
+	parser := Parser{Tokens: tokens, SymbolTable: table}
+	defs, err := parser.Parse()
+	if err != nil {
+		fmt.Fprint(r.out, err.Error())
+		return
+	}
+	if err := r.interpreter.Define(defs); err != nil {
+		fmt.Fprint(r.out, err.Error())
+		return
+	}
+	r.table = table
+
+	for _, def := range defs {
+		switch d := def.(type) {
+		case *AutomatonDef:
+			r.current = d.name.lexeme
+			fmt.Fprintf(r.out, "defined %s %s (%d states)\n", d.autType.lexeme, d.name.lexeme,
+				len(r.interpreter.Automaton(d.name.lexeme).States))
+		case *FunctionDef:
+			var params []string
+			for _, param := range d.params {
+				params = append(params, param.lexeme)
+			}
+			fmt.Fprintf(r.out, "defined fn %s(%s)\n", d.name.lexeme, strings.Join(params, ", "))
+		}
+	}
+}
+
+func (r *REPL) input(text string) {
+	automaton := r.interpreter.Automaton(r.current)
+	if automaton == nil {
+		fmt.Fprintln(r.out, "No automaton defined yet. Paste a definition or use :load.")
+		return
+	}
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	r.show(automaton, automaton.Run(text))
+}
+
+func (r *REPL) show(automaton *Automaton, result Result) {
+	path := strings.Join(result.Path(automaton.Initial), " -> ")
+	if r.trace {
+		WriteTrace(r.out, result)
+		fmt.Fprintf(r.out, "path: %s\n", path)
+		return
+	}
+	if result.Accepted {
+		fmt.Fprintf(r.out, "accepted: %s\n", path)
+	} else {
+		fmt.Fprintf(r.out, "rejected: %s (%s)\n", path, result.Rejection)
+	}
+}
+
+func (r *REPL) command(line string) bool {
+	fields := splitArgs(line)
+	name, args := fields[0], fields[1:]
+
+	if r.pending.Len() > 0 && name != ":cancel" && name != ":quit" && name != ":q" {
+		fmt.Fprintln(r.out, "Finish the definition first, or discard it with :cancel.")
+		return true
+	}
+
+	switch name {
+	case ":quit", ":q":
+		return false
+	case ":help", ":h":
+		fmt.Fprint(r.out, replHelp)
+	case ":cancel":
+		r.pending.Reset()
+	case ":load":
+		if len(args) != 1 {
+			fmt.Fprintln(r.out, "Usage: :load <file>")
+			break
+		}
+		source, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Fprintf(r.out, "Error reading file: %v\n", err)
+			break
+		}
+		r.define(source)
+	case ":use":
+		if len(args) != 1 || r.interpreter.Automaton(args[0]) == nil {
+			fmt.Fprintln(r.out, "Usage: :use <automaton>")
+			break
+		}
+		r.current = args[0]
+	case ":states":
+		target := r.current
+		if len(args) > 0 {
+			target = args[0]
+		}
+		automaton := r.interpreter.Automaton(target)
+		if automaton == nil {
+			fmt.Fprintf(r.out, "Unknown automaton '%s'.\n", target)
+			break
+		}
+		for _, state := range automaton.States {
+			fmt.Fprintf(r.out, "%-8s %s\n", state.stateType.lexeme, state.name.lexeme)
+			for _, t := range automaton.Transitions(state.name.lexeme) {
+				fmt.Fprintf(r.out, "         on %s\n", t)
+			}
+		}
+	case ":list":
+		for _, automaton := range r.interpreter.Automata() {
+			marker := " "
+			if automaton == r.current {
+				marker = "*"
+			}
+			fmt.Fprintf(r.out, "%s %s %s\n", marker, strings.ToLower(string(r.interpreter.Automaton(automaton).Kind)), automaton)
+		}
+		for _, function := range r.interpreter.Functions() {
+			fmt.Fprintf(r.out, "  fn %s\n", function)
+		}
+	case ":run":
+		if len(args) < 1 {
+			fmt.Fprintln(r.out, "Usage: :run <fn> <arg>...")
+			break
+		}
+		results, err := r.interpreter.Call(args[0], args[1:])
+		if err != nil {
+			fmt.Fprintln(r.out, strings.TrimRight(err.Error(), "\n"))
+			break
+		}
+		for _, result := range results {
+			fmt.Fprintf(r.out, "%s <- %q\n", result.Automaton, result.Input)
+			r.show(r.interpreter.Automaton(result.Automaton), result)
+		}
+	case ":trace":
+		if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+			fmt.Fprintln(r.out, "Usage: :trace on|off")
+			break
+		}
+		r.trace = args[0] == "on"
+	default:
+		fmt.Fprintf(r.out, "Unknown command '%s'. Type :help for help.\n", name)
+	}
+	return true
+}
+
+// Splits a command line on spaces, keeping double-quoted arguments whole
+func splitArgs(line string) []string {
+	var args []string
+	var current strings.Builder
+	inQuotes, started := false, false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+			started = true
+		case c == ' ' && !inQuotes:
+			if started {
+				args = append(args, current.String())
+				current.Reset()
+				started = false
+			}
+		default:
+			current.WriteByte(c)
+			started = true
+		}
+	}
+	if started {
+		args = append(args, current.String())
+	}
+	return args
+}
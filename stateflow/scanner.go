@@ -6,6 +6,7 @@ import (
 
 var keywords = map[string]TokenType{
 	"dfa":     DFA,
+	"nfa":     NFA,
 	"state":   STATE,
 	"initial": INITIAL,
 	"final":   FINAL,
@@ -22,6 +23,9 @@ type Scanner struct {
 	start   int
 	current int
 	line    int
+	// Offset of the first byte of the current line, used for columns
+	lineStart int
+	column    int
 	// inString bool
 	errors []error
 }
@@ -36,15 +40,17 @@ func (s *Scanner) ScanTokens() ([]Token, []error) {
 	s.start = 0
 	s.current = 0
 	s.line = 1
-	s.tokens = append(s.tokens, Token{BOF, "", s.line})
+	s.lineStart = 0
+	s.tokens = append(s.tokens, Token{BOF, "", s.line, 1})
 	for !s.isAtEnd() {
 		s.start = s.current
+		s.column = s.start - s.lineStart + 1
 		err := s.scanToken()
 		if err != nil {
 			s.errors = append(s.errors, err)
 		}
 	}
-	s.tokens = append(s.tokens, Token{EOF, "", s.line})
+	s.tokens = append(s.tokens, Token{EOF, "", s.line, s.current - s.lineStart + 1})
 	return s.tokens, s.errors
 }
 
@@ -106,6 +112,7 @@ func (s *Scanner) scanToken() error {
 			}
 		}
 		s.line += 1
+		s.lineStart = s.current
 	case ' ':
 	case '\t':
 	case '\r':
@@ -136,7 +143,7 @@ func (s *Scanner) advance() byte {
 // Store token, optional literal
 func (s *Scanner) addToken(tokenType TokenType) {
 	lexeme := string(s.Source[s.start:s.current])
-	s.tokens = append(s.tokens, Token{tokenType, lexeme, s.line})
+	s.tokens = append(s.tokens, Token{tokenType, lexeme, s.line, s.column})
 }
 
 // Verifies in next byte is as expected, if it is, advances to next byte
@@ -171,6 +178,7 @@ func (s *Scanner) string() error {
 	for s.peek() != '"' && !s.isAtEnd() {
 		if s.peek() == '\n' {
 			s.line += 1
+			s.lineStart = s.current + 1
 		}
 		s.advance()
 	}
@@ -25,6 +25,27 @@ func TestScannerBasicTokens(t *testing.T) {
 	}
 }
 
+func TestScannerNFAKeyword(t *testing.T) {
+	source := "nfa nfas"
+	scanner := Scanner{Source: []byte(source)}
+	tokens, errors := scanner.ScanTokens()
+
+	if len(errors) != 0 {
+		t.Fatalf("Expected no errors, got %d", len(errors))
+	}
+
+	expectedTokens := []TokenType{BOF, NFA, IDENTIFIER, EOF}
+	if len(tokens) != len(expectedTokens) {
+		t.Fatalf("Expected %d tokens, got %d", len(expectedTokens), len(tokens))
+	}
+
+	for i, expected := range expectedTokens {
+		if tokens[i].tokenType != expected {
+			t.Errorf("Token %d: expected %v, got %v", i, expected, tokens[i].tokenType)
+		}
+	}
+}
+
 func TestScannerArrows(t *testing.T) {
 	source := "-> <-"
 	scanner := Scanner{Source: []byte(source)}
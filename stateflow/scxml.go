@@ -0,0 +1,221 @@
+package stateflow
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// SCXML documents map an automaton to a flat statechart: `initial` and
+// `state` declarations become <state> elements, with the initial one named
+// by the `initial` attribute of <scxml>, and `final` declarations become
+// <final> elements. Each transition becomes a <transition> in its source
+// state, whose `event` attribute lists the string conditions.
+//
+// What SCXML cannot express is kept in attributes of the stateflow
+// namespace, which other tools ignore:
+//
+//	sf:kind="dfa"                on <scxml>, the kind of automaton; documents
+//	                             without it are read as an nfa
+//	sf:final="true"              on a <state>, for a final state with loops,
+//	                             since <final> cannot have transitions
+//	sf:conditions="/[0-9]+/"     on a <transition>, for regexes and strings
+//	                             that are not valid event names
+const (
+	scxmlNamespace     = "http://www.w3.org/2005/07/scxml"
+	stateflowNamespace = "https://github.com/jposo/stateflow"
+)
+
+// Event names are dot-separated tokens without spaces or wildcards
+var scxmlEvent = regexp.MustCompile(`^[A-Za-z0-9_:-]+(\.[A-Za-z0-9_:-]+)*$`)
+
+// exportSCXML writes an automaton as an SCXML document. A document holds
+// a single machine, so a file with several automata must name one.
+func exportSCXML(w io.Writer, graphs []*automatonGraph) error {
+	if len(graphs) > 1 {
+		return fmt.Errorf("an SCXML document holds one automaton; name one with file.sf:name")
+	}
+	graph := graphs[0]
+
+	var b strings.Builder
+	b.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(&b, "<scxml xmlns=\"%s\" xmlns:sf=\"%s\" version=\"1.0\" name=\"%s\"", scxmlNamespace, stateflowNamespace,
+		xmlEscaper.Replace(graph.name))
+	for _, state := range graph.states {
+		if state.kind == INITIAL {
+			fmt.Fprintf(&b, " initial=\"%s\"", xmlEscaper.Replace(state.name))
+		}
+	}
+	fmt.Fprintf(&b, " sf:kind=\"%s\">\n", strings.ToLower(string(graph.kind)))
+
+	for _, state := range graph.states {
+		var transitions []*TransDecl
+		for _, t := range graph.transitions {
+			if t.fromState.lexeme == state.name {
+				transitions = append(transitions, t)
+			}
+		}
+		id := xmlEscaper.Replace(state.name)
+		if state.kind == FINAL && len(transitions) == 0 {
+			fmt.Fprintf(&b, "  <final id=\"%s\"/>\n", id)
+			continue
+		}
+		final := ""
+		if state.kind == FINAL {
+			final = " sf:final=\"true\""
+		}
+		if len(transitions) == 0 {
+			fmt.Fprintf(&b, "  <state id=\"%s\"%s/>\n", id, final)
+			continue
+		}
+		fmt.Fprintf(&b, "  <state id=\"%s\"%s>\n", id, final)
+		for _, t := range transitions {
+			var events, others []string
+			for _, condition := range t.conditions {
+				if c, ok := condition.(StringCondition); ok && scxmlEvent.MatchString(unquote(c.value)) {
+					events = append(events, unquote(c.value))
+				} else {
+					others = append(others, conditionText(condition))
+				}
+			}
+			b.WriteString("    <transition")
+			if len(events) > 0 {
+				fmt.Fprintf(&b, " event=\"%s\"", xmlEscaper.Replace(strings.Join(events, " ")))
+			}
+			if len(others) > 0 {
+				fmt.Fprintf(&b, " sf:conditions=\"%s\"", xmlEscaper.Replace(strings.Join(others, " or ")))
+			}
+			fmt.Fprintf(&b, " target=\"%s\"/>\n", xmlEscaper.Replace(t.toState.lexeme))
+		}
+		b.WriteString("  </state>\n")
+	}
+	b.WriteString("</scxml>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// xmlNode is any element of a document, read without a fixed schema
+type xmlNode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Children []xmlNode  `xml:",any"`
+	Text     string     `xml:",chardata"`
+}
+
+// Returns the text of the element without surrounding space
+func (n xmlNode) text() string {
+	return strings.TrimSpace(n.Text)
+}
+
+// Returns the value of an attribute with no namespace, or of the given one
+func (n xmlNode) attr(name string, namespace ...string) string {
+	for _, attr := range n.Attrs {
+		if attr.Name.Local != name {
+			continue
+		}
+		if (len(namespace) == 0 && attr.Name.Space == "") ||
+			(len(namespace) > 0 && attr.Name.Space == namespace[0]) {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// importSCXML reads the flat statechart described above. Nested and
+// parallel states, guards and eventless transitions have no counterpart
+// and are rejected; executable content such as <onentry> is ignored.
+func importSCXML(r io.Reader) ([]*sourceAutomaton, error) {
+	var root xmlNode
+	if err := xml.NewDecoder(r).Decode(&root); err != nil {
+		return nil, fmt.Errorf("reading SCXML: %w", err)
+	}
+	if root.XMLName.Local != "scxml" {
+		return nil, fmt.Errorf("expected an <scxml> document, found <%s>", root.XMLName.Local)
+	}
+
+	automaton := &sourceAutomaton{kind: NFA, name: root.attr("name")}
+	if automaton.name == "" {
+		automaton.name = "machine"
+	}
+	if root.attr("kind", stateflowNamespace) == "dfa" {
+		automaton.kind = DFA
+	}
+
+	initial := root.attr("initial")
+	for _, child := range root.Children {
+		kind := child.XMLName.Local
+		switch kind {
+		case "state", "final":
+		case "parallel", "history", "initial":
+			return nil, fmt.Errorf("<%s> is not supported", kind)
+		default:
+			continue // Data model, scripts and other executable content
+		}
+
+		id := child.attr("id")
+		if id == "" {
+			return nil, fmt.Errorf("<%s> without an id", kind)
+		}
+		if initial == "" {
+			initial = id // The first state is initial by default
+		}
+		state := sourceState{name: id, kind: STATE}
+		switch {
+		case id == initial && (kind == "final" || child.attr("final", stateflowNamespace) == "true"):
+			return nil, fmt.Errorf("initial state '%s' cannot also be final", id)
+		case id == initial:
+			state.kind = INITIAL
+		case kind == "final" || child.attr("final", stateflowNamespace) == "true":
+			state.kind = FINAL
+		}
+		automaton.states = append(automaton.states, state)
+
+		for _, grandchild := range child.Children {
+			switch grandchild.XMLName.Local {
+			case "state", "parallel", "final", "history", "initial":
+				return nil, fmt.Errorf("state '%s': nested states are not supported", id)
+			case "transition":
+				t, err := scxmlTransition(id, grandchild)
+				if err != nil {
+					return nil, err
+				}
+				automaton.transitions = append(automaton.transitions, t)
+			}
+		}
+	}
+	if len(automaton.states) == 0 {
+		return nil, fmt.Errorf("the document has no states")
+	}
+	return []*sourceAutomaton{automaton}, nil
+}
+
+func scxmlTransition(from string, node xmlNode) (sourceTransition, error) {
+	target := node.attr("target")
+	if target == "" || len(strings.Fields(target)) > 1 {
+		return sourceTransition{}, fmt.Errorf("state '%s': a transition needs exactly one target", from)
+	}
+	if node.attr("cond") != "" {
+		return sourceTransition{}, fmt.Errorf("state '%s': guard conditions are not supported", from)
+	}
+
+	t := sourceTransition{from: from, to: target}
+	for _, event := range strings.Fields(node.attr("event")) {
+		if !scxmlEvent.MatchString(event) {
+			return sourceTransition{}, fmt.Errorf("state '%s': event '%s' is not supported", from, event)
+		}
+		t.conditions = append(t.conditions, `"`+event+`"`)
+	}
+	if text := node.attr("conditions", stateflowNamespace); text != "" {
+		conditions, err := conditionsSource(text)
+		if err != nil {
+			return sourceTransition{}, fmt.Errorf("state '%s': %w", from, err)
+		}
+		t.conditions = append(t.conditions, conditions...)
+	}
+	if len(t.conditions) == 0 {
+		return sourceTransition{}, fmt.Errorf("state '%s': eventless transitions are not supported", from)
+	}
+	return t, nil
+}
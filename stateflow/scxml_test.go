@@ -0,0 +1,109 @@
+package stateflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSCXMLRoundTrip(t *testing.T) {
+	source := `dfa counter {
+  initial q0;
+  state q1;
+  final q2;
+
+  on q0 -> q1 when "inc";
+  on q1 -> q2 when "inc" or /[0-9]+/ or "two words";
+  on q2 -> q2 when "reset";
+}
+`
+	scxml := export(t, "scxml", source)
+	for _, fragment := range []string{
+		`initial="q0" sf:kind="dfa">`,
+		`<transition event="inc" sf:conditions="/[0-9]+/ or &quot;two words&quot;" target="q2"/>`,
+		`<state id="q2" sf:final="true">`,
+	} {
+		if !strings.Contains(scxml, fragment) {
+			t.Errorf("Expected %q in output:\n%s", fragment, scxml)
+		}
+	}
+
+	imported, err := Import(strings.NewReader(scxml), "scxml", "machine")
+	if err != nil {
+		t.Fatalf("Expected no error importing, got: %v", err)
+	}
+	if string(imported) != source {
+		t.Errorf("Expected the round trip to give back the source, got:\n%s", imported)
+	}
+}
+
+func TestSCXMLImportForeignDocument(t *testing.T) {
+	document := `<scxml xmlns="http://www.w3.org/2005/07/scxml" version="1.0" name="order-flow">
+  <datamodel><data id="total"/></datamodel>
+  <state id="new">
+    <onentry><log expr="'created'"/></onentry>
+    <transition event="pay" target="paid"/>
+    <transition event="cancel" target="final"/>
+  </state>
+  <state id="paid"><transition event="ship" target="2done"/></state>
+  <final id="final"/>
+  <final id="2done"/>
+</scxml>`
+
+	expected := `nfa order_flow {
+  initial new;
+  state paid;
+  final final_;
+  final s2done;
+
+  on new -> paid    when "pay";
+  on new -> final_  when "cancel";
+  on paid -> s2done when "ship";
+}
+`
+	imported, err := Import(strings.NewReader(document), "scxml", "machine")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if string(imported) != expected {
+		t.Errorf("Unexpected source:\n%s", imported)
+	}
+}
+
+func TestSCXMLImportUnnamedDocument(t *testing.T) {
+	document := `<scxml initial="a"><state id="a"><transition event="go" target="b"/></state><final id="b"/></scxml>`
+	imported, err := Import(strings.NewReader(document), "scxml", "orders")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !strings.HasPrefix(string(imported), "nfa machine {") {
+		t.Errorf("Expected the document to be named machine:\n%s", imported)
+	}
+}
+
+func TestSCXMLImportErrors(t *testing.T) {
+	tests := []struct {
+		document string
+		message  string
+	}{
+		{`<scxml><state id="a"><state id="b"/></state></scxml>`, "nested states"},
+		{`<scxml><state id="a"><transition event="go" cond="x > 1" target="a"/></state></scxml>`, "guard"},
+		{`<scxml><state id="a"><transition target="a"/></state></scxml>`, "eventless"},
+		{`<scxml><state id="a"><transition event="*" target="a"/></state></scxml>`, "event '*'"},
+		{`<scxml xmlns:sf="https://github.com/jposo/stateflow"><state id="a"><transition sf:conditions="&quot;x&quot;; }" target="a"/></state></scxml>`, "joined with 'or'"},
+		{`<scxml sf:kind="dfa" xmlns:sf="https://github.com/jposo/stateflow"><state id="a"><transition event="x" target="b"/><transition event="x" target="c"/></state><final id="b"/><final id="c"/></scxml>`, "not valid"},
+		{`<machine/>`, "expected an <scxml> document"},
+	}
+	for _, test := range tests {
+		_, err := Import(strings.NewReader(test.document), "scxml", "machine")
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Expected an error containing %q for %s, got: %v", test.message, test.document, err)
+		}
+	}
+}
+
+func TestSCXMLExportNeedsOneAutomaton(t *testing.T) {
+	var b strings.Builder
+	if err := Export(&b, "scxml", getDefinitions(t, counterSource+"\n"+parallelSource)); err == nil {
+		t.Error("Expected an error exporting two automata to one document")
+	}
+}
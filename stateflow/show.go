@@ -0,0 +1,202 @@
+package stateflow
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// ShowStyle selects how Show draws an automaton
+type ShowStyle int
+
+const (
+	ShowAuto    ShowStyle = iota // Diagram for small automata, matrix for large ones
+	ShowDiagram                  // A box per state with its transitions below
+	ShowMatrix                   // A table of states by conditions
+)
+
+// showMatrixStates is the number of states from which ShowAuto picks the
+// matrix, since a diagram of that many boxes no longer fits a screen
+const showMatrixStates = 9
+
+// boxChars is the set of characters used to draw on a terminal
+type boxChars struct {
+	h, v, tl, tr, bl, br, teeDown    string // Boxes of ordinary states
+	dh, dv, dtl, dtr, dbl, dbr, dtee string // Boxes of final states
+	branch, lastBranch, arrow, loop  string // Transitions
+	cross, teeLeft, teeRight, teeUp  string // Table joints
+	entry, initial                   string // Markers of the initial state
+}
+
+var unicodeChars = boxChars{
+	h: "─", v: "│", tl: "┌", tr: "┐", bl: "└", br: "┘", teeDown: "┬",
+	dh: "═", dv: "║", dtl: "╔", dtr: "╗", dbl: "╚", dbr: "╝", dtee: "╤",
+	branch: "├─", lastBranch: "└─", arrow: "─▶", loop: "↺",
+	cross: "┼", teeLeft: "├", teeRight: "┤", teeUp: "┴",
+	entry: "───▶", initial: "→",
+}
+
+var asciiChars = boxChars{
+	h: "-", v: "|", tl: "+", tr: "+", bl: "+", br: "+", teeDown: "+",
+	dh: "=", dv: "|", dtl: "#", dtr: "#", dbl: "#", dbr: "#", dtee: "#",
+	branch: "|-", lastBranch: "`-", arrow: "->", loop: "(loop)",
+	cross: "+", teeLeft: "+", teeRight: "+", teeUp: "+",
+	entry: "--->", initial: "->",
+}
+
+// Show draws the automata among the definitions for a terminal, either as
+// box diagrams or as transition matrices. With ascii set, only ASCII
+// characters are used.
+func Show(w io.Writer, defs []Definition, style ShowStyle, ascii bool) error {
+	graphs := automatonGraphs(defs)
+	if len(graphs) == 0 {
+		return fmt.Errorf("no automata to show")
+	}
+	chars := unicodeChars
+	if ascii {
+		chars = asciiChars
+	}
+
+	var b strings.Builder
+	for i, graph := range graphs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s %s\n", strings.ToLower(string(graph.kind)), graph.name)
+		matrix := style == ShowMatrix || (style == ShowAuto && len(graph.states) >= showMatrixStates)
+		if matrix {
+			writeMatrix(&b, graph, chars)
+		} else {
+			writeDiagram(&b, graph, chars)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Draws every state as a box, in the order of the layout so the initial
+// state comes first, with its outgoing transitions branching below it
+func writeDiagram(b *strings.Builder, graph *automatonGraph, chars boxChars) {
+	layout := layoutGraph(graph)
+	states := slices.Clone(graph.states)
+	slices.SortStableFunc(states, func(a, b graphState) int {
+		pa, pb := layout.position[a.name], layout.position[b.name]
+		return cmp.Or(cmp.Compare(pa.X, pb.X), cmp.Compare(pa.Y, pb.Y))
+	})
+
+	indent := strings.Repeat(" ", utf8.RuneCountInString(chars.entry)+3)
+	for _, state := range states {
+		h, v, tl, tr, bl, br, tee := chars.h, chars.v, chars.tl, chars.tr, chars.bl, chars.br, chars.teeDown
+		if state.kind == FINAL {
+			h, v, tl, tr, bl, br, tee = chars.dh, chars.dv, chars.dtl, chars.dtr, chars.dbl, chars.dbr, chars.dtee
+		}
+		var edges []*graphEdge
+		for _, edge := range graph.edges {
+			if edge.from == state.name {
+				edges = append(edges, edge)
+			}
+		}
+
+		width := utf8.RuneCountInString(state.name) + 2
+		entry := indent
+		if state.kind == INITIAL {
+			entry = "  " + chars.entry + " "
+		}
+		bottom := strings.Repeat(h, width)
+		if len(edges) > 0 {
+			bottom = h + tee + strings.Repeat(h, width-2)
+		}
+		fmt.Fprintf(b, "\n%s%s%s%s\n", indent, tl, strings.Repeat(h, width), tr)
+		fmt.Fprintf(b, "%s%s %s %s\n", entry, v, state.name, v)
+		fmt.Fprintf(b, "%s%s%s%s\n", indent, bl, bottom, br)
+
+		for i, edge := range edges {
+			branch := chars.branch
+			if i == len(edges)-1 {
+				branch = chars.lastBranch
+			}
+			target := chars.arrow + " " + edge.to
+			if edge.to == edge.from {
+				target = chars.loop
+			}
+			fmt.Fprintf(b, "%s  %s %s %s\n", indent, branch, edge.label(), target)
+		}
+	}
+}
+
+// Draws a table with a row per state and a column per condition, whose
+// cells hold the states reached. Initial and final states are marked
+// before their name.
+func writeMatrix(b *strings.Builder, graph *automatonGraph, chars boxChars) {
+	var symbols []string
+	for _, edge := range graph.edges {
+		for _, condition := range edge.conditions {
+			if text := conditionText(condition); !slices.Contains(symbols, text) {
+				symbols = append(symbols, text)
+			}
+		}
+	}
+
+	marker := utf8.RuneCountInString(chars.initial) + 1
+	rows := [][]string{append([]string{""}, symbols...)}
+	for _, state := range graph.states {
+		row := []string{markState(state, chars, marker) + state.name}
+		for _, symbol := range symbols {
+			var targets []string
+			for _, edge := range graph.edges {
+				if edge.from != state.name {
+					continue
+				}
+				for _, condition := range edge.conditions {
+					if conditionText(condition) == symbol && !slices.Contains(targets, edge.to) {
+						targets = append(targets, edge.to)
+					}
+				}
+			}
+			row = append(row, strings.Join(targets, ","))
+		}
+		rows = append(rows, row)
+	}
+
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+	rule := func(left, middle, right string) {
+		var parts []string
+		for _, width := range widths {
+			parts = append(parts, strings.Repeat(chars.h, width+2))
+		}
+		fmt.Fprintf(b, "%s%s%s\n", left, strings.Join(parts, middle), right)
+	}
+
+	rule(chars.tl, chars.teeDown, chars.tr)
+	for i, row := range rows {
+		var cells []string
+		for j, cell := range row {
+			cells = append(cells, " "+cell+strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell))+" ")
+		}
+		fmt.Fprintf(b, "%s%s%s\n", chars.v, strings.Join(cells, chars.v), chars.v)
+		if i == 0 {
+			rule(chars.teeLeft, chars.cross, chars.teeRight)
+		}
+	}
+	rule(chars.bl, chars.teeUp, chars.br)
+}
+
+// Returns the marker of a state in the matrix, padded to a fixed width
+func markState(state graphState, chars boxChars, width int) string {
+	marker := ""
+	switch state.kind {
+	case INITIAL:
+		marker = chars.initial
+	case FINAL:
+		marker = "*"
+	}
+	return marker + strings.Repeat(" ", width-utf8.RuneCountInString(marker))
+}
@@ -0,0 +1,71 @@
+package stateflow
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func show(t *testing.T, source string, style ShowStyle, ascii bool) string {
+	var b bytes.Buffer
+	if err := Show(&b, getDefinitions(t, source), style, ascii); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	return b.String()
+}
+
+func TestShowDiagram(t *testing.T) {
+	expected := `dfa counter
+
+       ┌────┐
+  ───▶ │ q0 │
+       └─┬──┘
+         └─ "inc" ─▶ q1
+
+       ┌────┐
+       │ q1 │
+       └─┬──┘
+         └─ "inc" or /[0-9]+/ ─▶ q2
+
+       ╔════╗
+       ║ q2 ║
+       ╚═╤══╝
+         └─ "reset" ↺
+`
+	if diagram := show(t, counterSource, ShowAuto, false); diagram != expected {
+		t.Errorf("Unexpected diagram:\n%s", diagram)
+	}
+}
+
+func TestShowMatrix(t *testing.T) {
+	expected := `dfa counter
++-------+-------+----------+---------+
+|       | "inc" | /[0-9]+/ | "reset" |
++-------+-------+----------+---------+
+| -> q0 | q1    |          |         |
+|    q1 | q2    | q2       |         |
+| *  q2 |       |          | q2      |
++-------+-------+----------+---------+
+`
+	if matrix := show(t, counterSource, ShowMatrix, true); matrix != expected {
+		t.Errorf("Unexpected matrix:\n%s", matrix)
+	}
+}
+
+func TestShowAutoPicksMatrixForLargeAutomata(t *testing.T) {
+	var source strings.Builder
+	source.WriteString("nfa chain {\n  initial s0;\n")
+	for i := 1; i < showMatrixStates; i++ {
+		fmt.Fprintf(&source, "  state s%d;\n", i)
+	}
+	for i := 1; i < showMatrixStates; i++ {
+		fmt.Fprintf(&source, "  on s%d -> s%d when \"a\";\n", i-1, i)
+	}
+	source.WriteString("}\n")
+
+	output := show(t, source.String(), ShowAuto, false)
+	if !strings.Contains(output, "│ → s0 │ s1") {
+		t.Errorf("Expected a matrix, got:\n%s", output)
+	}
+}
@@ -0,0 +1,79 @@
+package stateflow
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// exportMermaid writes a Mermaid stateDiagram-v2. A single automaton is
+// drawn at the top level; several automata become composite states so
+// they fit in one diagram, with state ids prefixed by the automaton name
+// since Mermaid ids are global to the diagram.
+func exportMermaid(w io.Writer, graphs []*automatonGraph) error {
+	var b strings.Builder
+	b.WriteString("stateDiagram-v2\n")
+	if len(graphs) == 1 {
+		writeStateDiagram(&b, graphs[0], "  ", "", mermaidEscaper)
+	}
+	if len(graphs) > 1 {
+		for i, graph := range graphs {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "  state %s {\n", graph.name)
+			writeStateDiagram(&b, graph, "    ", graph.name+"_", mermaidEscaper)
+			b.WriteString("  }\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// exportPlantUML writes one @startuml block per automaton
+func exportPlantUML(w io.Writer, graphs []*automatonGraph) error {
+	var b strings.Builder
+	for i, graph := range graphs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "@startuml %s\n", graph.name)
+		b.WriteString("hide empty description\n")
+		writeStateDiagram(&b, graph, "", "", plantUMLEscaper)
+		b.WriteString("@enduml\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Writes the body shared by Mermaid and PlantUML: the states, an arrow
+// from [*] into the initial state, the transitions and an arrow from each
+// final state into [*]. A non-empty prefix is added to every state id.
+func writeStateDiagram(b *strings.Builder, graph *automatonGraph, indent string, prefix string, escaper *strings.Replacer) {
+	for _, state := range graph.states {
+		if prefix == "" {
+			fmt.Fprintf(b, "%sstate %s\n", indent, state.name)
+		} else {
+			fmt.Fprintf(b, "%sstate \"%s\" as %s%s\n", indent, state.name, prefix, state.name)
+		}
+	}
+	for _, state := range graph.states {
+		if state.kind == INITIAL {
+			fmt.Fprintf(b, "%s[*] --> %s%s\n", indent, prefix, state.name)
+		}
+	}
+	for _, edge := range graph.edges {
+		fmt.Fprintf(b, "%s%s%s --> %s%s : %s\n", indent, prefix, edge.from, prefix, edge.to, escaper.Replace(edge.label()))
+	}
+	for _, state := range graph.states {
+		if state.kind == FINAL {
+			fmt.Fprintf(b, "%s%s%s --> [*]\n", indent, prefix, state.name)
+		}
+	}
+}
+
+// Mermaid ends statements at ';' and reads '#' as the start of an entity
+var mermaidEscaper = strings.NewReplacer("#", "#35;", ";", "#59;")
+
+// PlantUML reads '\n' in labels as a line break
+var plantUMLEscaper = strings.NewReplacer(`\`, `\\`)
@@ -0,0 +1,165 @@
+package stateflow
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+)
+
+// Stream matches an input that arrives in pieces, through Write or
+// ReadFrom, without keeping it. The dfa is compiled into a byte table, so
+// a chunk may end anywhere, even inside a character, and the position
+// between chunks is a single state that can be saved with MarshalBinary
+// and restored into a stream of the same automaton.
+type Stream struct {
+	automaton   string
+	table       *matchTable
+	fingerprint uint64
+	state       int
+	offset      int64
+}
+
+// streamVersion is the first byte of a serialized stream, changed
+// whenever the encoding changes
+const streamVersion = 1
+
+// NewStream returns a stream over the empty input of a dfa. Nfa automata
+// cannot be streamed.
+func NewStream(automaton *Automaton) (*Stream, error) {
+	table, err := compileTable(automaton)
+	if err != nil {
+		return nil, err
+	}
+	return &Stream{
+		automaton:   automaton.Name,
+		table:       table,
+		fingerprint: table.fingerprint(),
+		state:       table.start,
+	}, nil
+}
+
+// Write consumes a chunk of input. It never fails; once the stream is
+// rejected the rest of the input is ignored.
+func (s *Stream) Write(p []byte) (int, error) {
+	consume(s, p)
+	return len(p), nil
+}
+
+// WriteString consumes a chunk of input, like Write
+func (s *Stream) WriteString(p string) (int, error) {
+	consume(s, p)
+	return len(p), nil
+}
+
+func consume[T string | []byte](s *Stream, p T) {
+	t := s.table
+	state, i := s.state, 0
+	for ; i < len(p) && state != 0; i++ {
+		state = t.next[state*t.width+t.classes[p[i]]]
+	}
+	s.state = state
+	s.offset += int64(i)
+}
+
+// ReadFrom consumes input from the reader until its end, or until the
+// stream is rejected, so a long input that fails early is not read in
+// full
+func (s *Stream) ReadFrom(r io.Reader) (int64, error) {
+	buf := make([]byte, 64*1024)
+	var total int64
+	for !s.Rejected() {
+		n, err := r.Read(buf)
+		s.Write(buf[:n])
+		total += int64(n)
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// Accepted reports whether the input consumed so far is accepted
+func (s *Stream) Accepted() bool {
+	return s.table.accept[s.state]
+}
+
+// Rejected reports whether no continuation of the input consumed so far
+// can be accepted
+func (s *Stream) Rejected() bool {
+	return s.state == 0
+}
+
+// Offset returns the number of bytes consumed so far. Once the stream is
+// rejected, the last byte consumed is the one that was rejected.
+func (s *Stream) Offset() int64 {
+	return s.offset
+}
+
+// Reset starts the stream over with an empty input
+func (s *Stream) Reset() {
+	s.state = s.table.start
+	s.offset = 0
+}
+
+// MarshalBinary encodes the position of the stream, with a fingerprint of
+// the automaton it belongs to
+func (s *Stream) MarshalBinary() ([]byte, error) {
+	data := []byte{streamVersion}
+	data = binary.BigEndian.AppendUint64(data, s.fingerprint)
+	data = binary.AppendUvarint(data, uint64(s.state))
+	data = binary.AppendUvarint(data, uint64(s.offset))
+	return data, nil
+}
+
+// UnmarshalBinary restores a position encoded by MarshalBinary. It fails
+// when the position was saved from another automaton, or from a version
+// of this one with different conditions.
+func (s *Stream) UnmarshalBinary(data []byte) error {
+	if len(data) < 9 || data[0] != streamVersion {
+		return errors.New("not a stream position, or from an unsupported version")
+	}
+	if binary.BigEndian.Uint64(data[1:9]) != s.fingerprint {
+		return fmt.Errorf("the stream position was not saved from automaton '%s'", s.automaton)
+	}
+	data = data[9:]
+	state, n := binary.Uvarint(data)
+	if n <= 0 || state >= uint64(len(s.table.accept)) {
+		return errors.New("invalid stream state")
+	}
+	offset, m := binary.Uvarint(data[n:])
+	if m <= 0 || n+m != len(data) || offset > 1<<63-1 {
+		return errors.New("invalid stream offset")
+	}
+	s.state, s.offset = int(state), int64(offset)
+	return nil
+}
+
+// Returns a hash of everything that decides what the table accepts, so
+// states of the same table can be recognized across processes
+func (t *matchTable) fingerprint() uint64 {
+	h := fnv.New64a()
+	var buf []byte
+	buf = binary.AppendUvarint(buf, uint64(len(t.accept)))
+	buf = binary.AppendUvarint(buf, uint64(t.start))
+	buf = binary.AppendUvarint(buf, uint64(t.width))
+	for _, accept := range t.accept {
+		if accept {
+			buf = append(buf, 1)
+		} else {
+			buf = append(buf, 0)
+		}
+	}
+	for _, class := range t.classes {
+		buf = binary.AppendUvarint(buf, uint64(class))
+	}
+	for _, target := range t.next {
+		buf = binary.AppendUvarint(buf, uint64(target))
+	}
+	h.Write(buf)
+	return h.Sum64()
+}
@@ -0,0 +1,107 @@
+package stateflow
+
+import (
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func getStream(t *testing.T, source, name string) *Stream {
+	stream, err := NewStream(getInterpreter(t, source).Automaton(name))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	return stream
+}
+
+func TestStreamChunks(t *testing.T) {
+	stream := getStream(t, counterSource, "counter")
+	automaton := getInterpreter(t, counterSource).Automaton("counter")
+	for _, input := range []string{"", "inc", "incinc", "inc42reset", "incincx", "inc\xffinc"} {
+		for split := 0; split <= len(input); split++ {
+			stream.Reset()
+			stream.WriteString(input[:split])
+			stream.Write([]byte(input[split:]))
+			if want := automaton.Run(input).Accepted; stream.Accepted() != want {
+				t.Errorf("Expected %v for %q split at %d, got %v", want, input, split, stream.Accepted())
+			}
+		}
+	}
+}
+
+func TestStreamSplitCharacters(t *testing.T) {
+	source := `dfa accents {
+	initial q0;
+	final q1;
+
+	on q0 -> q1 when "é";
+	on q1 -> q1 when /[à-ü]/;
+}`
+	stream := getStream(t, source, "accents")
+	for _, b := range []byte("éèü") {
+		stream.Write([]byte{b})
+	}
+	if !stream.Accepted() {
+		t.Error("Expected characters split across writes to be accepted")
+	}
+	stream.Write([]byte{0xC3})
+	if stream.Accepted() || stream.Rejected() {
+		t.Error("Expected an unfinished character to be neither accepted nor rejected")
+	}
+}
+
+func TestStreamRejection(t *testing.T) {
+	stream := getStream(t, counterSource, "counter")
+	stream.WriteString("incx")
+	if !stream.Rejected() || stream.Offset() != 4 {
+		t.Errorf("Expected rejection at the fourth byte, got %v after %d bytes", stream.Rejected(), stream.Offset())
+	}
+
+	// Reading stops once the input is rejected
+	stream.Reset()
+	input := "incx" + strings.Repeat("inc", 1<<20)
+	read, err := stream.ReadFrom(iotest.HalfReader(strings.NewReader(input)))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !stream.Rejected() || read >= int64(len(input)) {
+		t.Errorf("Expected reading to stop early, read %d of %d bytes", read, len(input))
+	}
+}
+
+func TestStreamResume(t *testing.T) {
+	stream := getStream(t, counterSource, "counter")
+	stream.WriteString("inc")
+	data, err := stream.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	resumed := getStream(t, counterSource, "counter")
+	if err := resumed.UnmarshalBinary(data); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	resumed.WriteString("12")
+	if !resumed.Accepted() || resumed.Offset() != 5 {
+		t.Errorf("Expected the resumed stream to accept after 5 bytes, got %v after %d", resumed.Accepted(), resumed.Offset())
+	}
+
+	other := getStream(t, `dfa other {
+	initial q0;
+	final q1;
+
+	on q0 -> q1 when "inc";
+}`, "other")
+	if err := other.UnmarshalBinary(data); err == nil {
+		t.Error("Expected an error restoring the position into another automaton")
+	}
+	if err := resumed.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Error("Expected an error for a truncated position")
+	}
+}
+
+func TestStreamRejectsNFA(t *testing.T) {
+	if _, err := NewStream(getInterpreter(t, parallelSource).Automaton("tags")); err == nil {
+		t.Error("Expected an error streaming an nfa")
+	}
+}
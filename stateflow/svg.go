@@ -0,0 +1,200 @@
+package stateflow
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// Sizes of the SVG drawing, in pixels
+const (
+	svgMargin     = 60.0
+	svgRowHeight  = 100.0
+	svgRadius     = 22.0
+	svgFinalInset = 4.0 // Gap between the two circles of a final state
+	svgTitleSize  = 16.0
+	svgEntryArrow = 30.0 // Length of the arrow into the initial state
+	svgCharWidth  = 7.0  // Rough width of a label character
+	svgLoopHeight = 2.6  // Height of a self-loop, in radii
+	svgCurveBend  = 0.2  // Offset of curved edges, in column widths
+)
+
+const svgHeader = `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f" font-family="sans-serif">
+  <defs>
+    <marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto-start-reverse">
+      <path d="M 0 0 L 10 5 L 0 10 z"/>
+    </marker>
+  </defs>
+  <rect width="100%%" height="100%%" fill="white"/>
+`
+
+// RenderSVG draws the automata among the definitions as an SVG image, one
+// below the other, laid out without any external tool
+func RenderSVG(w io.Writer, defs []Definition) error {
+	graphs := automatonGraphs(defs)
+	if len(graphs) == 0 {
+		return fmt.Errorf("no automata to render")
+	}
+
+	var body strings.Builder
+	width, top := 0.0, 0.0
+	for _, graph := range graphs {
+		drawing := svgDrawing{graph: graph, layout: layoutGraph(graph), top: top}
+		drawing.draw(&body)
+		width = max(width, drawing.width())
+		top += drawing.height()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, svgHeader, width, top, width, top)
+	b.WriteString(body.String())
+	b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// svgDrawing places one automaton in the image, starting at a given height
+type svgDrawing struct {
+	graph  *automatonGraph
+	layout *graphLayout
+	top    float64
+}
+
+// Columns are wide enough for the longest label between them
+func (d *svgDrawing) columnWidth() float64 {
+	longest := 0
+	for _, edge := range d.graph.edges {
+		longest = max(longest, len(edge.label()))
+	}
+	return max(130, svgCharWidth*float64(longest)+2*svgRadius+30)
+}
+
+func (d *svgDrawing) width() float64 {
+	title := svgCharWidth * float64(len(d.graph.name)+4)
+	return max(2*svgMargin+float64(d.layout.columns-1)*d.columnWidth(), 2*svgMargin+title)
+}
+
+func (d *svgDrawing) height() float64 {
+	return 2*svgMargin + svgTitleSize + float64(d.layout.rows-1)*svgRowHeight
+}
+
+// Converts grid units to pixels
+func (d *svgDrawing) pixels(p point) point {
+	return point{
+		svgMargin + p.X*d.columnWidth(),
+		d.top + svgMargin + svgTitleSize + p.Y*svgRowHeight,
+	}
+}
+
+func (d *svgDrawing) radius(state string) float64 {
+	if i := d.graph.stateIndex(state); i >= 0 && d.graph.states[i].kind == FINAL {
+		return svgRadius + svgFinalInset
+	}
+	return svgRadius
+}
+
+func (d *svgDrawing) draw(b *strings.Builder) {
+	fmt.Fprintf(b, "  <g class=\"automaton\" id=\"%s\">\n", xmlEscaper.Replace(d.graph.name))
+	fmt.Fprintf(b, "    <text x=\"%.1f\" y=\"%.1f\" font-size=\"%.0f\" font-weight=\"bold\">%s %s</text>\n",
+		svgMargin/2, d.top+svgMargin/2+svgTitleSize/2, svgTitleSize,
+		strings.ToLower(string(d.graph.kind)), xmlEscaper.Replace(d.graph.name))
+
+	for _, edge := range d.graph.edges {
+		d.drawEdge(b, edge)
+	}
+	for _, state := range d.graph.states {
+		c := d.pixels(d.layout.position[state.name])
+		if state.kind == INITIAL {
+			fmt.Fprintf(b, "    <line x1=\"%.1f\" y1=\"%.1f\" x2=\"%.1f\" y2=\"%.1f\" stroke=\"black\" marker-end=\"url(#arrow)\"/>\n",
+				c.X-svgRadius-svgEntryArrow, c.Y, c.X-svgRadius, c.Y)
+		}
+		fmt.Fprintf(b, "    <circle cx=\"%.1f\" cy=\"%.1f\" r=\"%.1f\" fill=\"white\" stroke=\"black\"/>\n", c.X, c.Y, svgRadius)
+		if state.kind == FINAL {
+			fmt.Fprintf(b, "    <circle cx=\"%.1f\" cy=\"%.1f\" r=\"%.1f\" fill=\"none\" stroke=\"black\"/>\n", c.X, c.Y, svgRadius+svgFinalInset)
+		}
+		fmt.Fprintf(b, "    <text x=\"%.1f\" y=\"%.1f\" text-anchor=\"middle\" dominant-baseline=\"central\" font-size=\"14\">%s</text>\n",
+			c.X, c.Y, xmlEscaper.Replace(state.name))
+	}
+	b.WriteString("  </g>\n")
+}
+
+// Draws an edge as a loop above its state, a curve when the opposite edge
+// also exists, or a polyline through the bends of the layout
+func (d *svgDrawing) drawEdge(b *strings.Builder, edge *graphEdge) {
+	from := d.pixels(d.layout.position[edge.from])
+	to := d.pixels(d.layout.position[edge.to])
+
+	if edge.from == edge.to {
+		r := d.radius(edge.from)
+		start := point{from.X + r*math.Cos(-2*math.Pi/3), from.Y + r*math.Sin(-2*math.Pi/3)}
+		end := point{from.X + r*math.Cos(-math.Pi/3), from.Y + r*math.Sin(-math.Pi/3)}
+		top := from.Y - svgLoopHeight*svgRadius
+		fmt.Fprintf(b, "    <path d=\"M %.1f %.1f C %.1f %.1f %.1f %.1f %.1f %.1f\" fill=\"none\" stroke=\"black\" marker-end=\"url(#arrow)\"/>\n",
+			start.X, start.Y, from.X-svgRadius, top, from.X+svgRadius, top, end.X, end.Y)
+		d.drawLabel(b, edge, point{from.X, top + 0.25*svgRadius})
+		return
+	}
+
+	points := []point{from}
+	for _, bend := range d.layout.bends[edge] {
+		points = append(points, d.pixels(bend))
+	}
+	points = append(points, to)
+
+	if len(points) == 2 && hasOpposite(d.graph, edge) {
+		// Bend to the left of the direction of travel, so the two edges of
+		// a pair never overlap
+		dx, dy := to.X-from.X, to.Y-from.Y
+		length := math.Hypot(dx, dy)
+		offset := svgCurveBend * d.columnWidth()
+		control := point{(from.X+to.X)/2 + dy/length*offset, (from.Y+to.Y)/2 - dx/length*offset}
+		start := towards(from, control, d.radius(edge.from))
+		end := towards(to, control, d.radius(edge.to))
+		fmt.Fprintf(b, "    <path d=\"M %.1f %.1f Q %.1f %.1f %.1f %.1f\" fill=\"none\" stroke=\"black\" marker-end=\"url(#arrow)\"/>\n",
+			start.X, start.Y, control.X, control.Y, end.X, end.Y)
+		d.drawLabel(b, edge, point{(start.X + 2*control.X + end.X) / 4, (start.Y + 2*control.Y + end.Y) / 4})
+		return
+	}
+
+	points[0] = towards(points[0], points[1], d.radius(edge.from))
+	last := len(points) - 1
+	points[last] = towards(points[last], points[last-1], d.radius(edge.to))
+	var path []string
+	for _, p := range points {
+		path = append(path, fmt.Sprintf("%.1f %.1f", p.X, p.Y))
+	}
+	fmt.Fprintf(b, "    <path d=\"M %s\" fill=\"none\" stroke=\"black\" marker-end=\"url(#arrow)\"/>\n", strings.Join(path, " L "))
+
+	// Label the middle segment
+	middle := len(points) / 2
+	a, c := points[middle-1], points[middle]
+	d.drawLabel(b, edge, point{(a.X + c.X) / 2, (a.Y + c.Y) / 2})
+}
+
+// Writes the conditions of an edge just above a point, with regex
+// conditions in italics
+func (d *svgDrawing) drawLabel(b *strings.Builder, edge *graphEdge, at point) {
+	var parts []string
+	for _, condition := range edge.conditions {
+		text := xmlEscaper.Replace(conditionText(condition))
+		if _, ok := condition.(RegexCondition); ok {
+			text = "<tspan font-style=\"italic\">" + text + "</tspan>"
+		}
+		parts = append(parts, text)
+	}
+	fmt.Fprintf(b, "    <text x=\"%.1f\" y=\"%.1f\" text-anchor=\"middle\" font-size=\"12\">%s</text>\n",
+		at.X, at.Y-6, strings.Join(parts, " or "))
+}
+
+// Returns the point at a distance from p in the direction of target
+func towards(p, target point, distance float64) point {
+	dx, dy := target.X-p.X, target.Y-p.Y
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return p
+	}
+	return point{p.X + dx/length*distance, p.Y + dy/length*distance}
+}
+
+var xmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&apos;")
@@ -0,0 +1,122 @@
+package stateflow
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+const cyclicSource = `dfa cycle {
+	initial q0;
+	state q1;
+	state q2;
+	final q3;
+
+	on q0 -> q1 when "a";
+	on q1 -> q0 when "b";
+	on q1 -> q2 when "c";
+	on q0 -> q2 when "d";
+	on q2 -> q2 when "e";
+	on q2 -> q0 when "f";
+	on q2 -> q3 when "g";
+}`
+
+func TestLayoutLayers(t *testing.T) {
+	graph := automatonGraphs(getDefinitions(t, cyclicSource))[0]
+	layout := layoutGraph(graph)
+
+	expected := map[string]float64{"q0": 0, "q1": 1, "q2": 2, "q3": 3}
+	for state, column := range expected {
+		if x := layout.position[state].X; x != column {
+			t.Errorf("Expected %s in layer %v, got %v", state, column, x)
+		}
+	}
+	if layout.columns != 4 {
+		t.Errorf("Expected 4 layers, got %d", layout.columns)
+	}
+
+	seen := make(map[point]string)
+	for state, p := range layout.position {
+		if other, ok := seen[p]; ok {
+			t.Errorf("States %s and %s share position %v", state, other, p)
+		}
+		seen[p] = state
+	}
+
+	// Both edges spanning two layers bend once, in the layer between
+	for _, edge := range graph.edges {
+		bends := layout.bends[edge]
+		switch edge.from + edge.to {
+		case "q0q2", "q2q0":
+			if len(bends) != 1 || bends[0].X != 1 {
+				t.Errorf("Expected %s -> %s to bend in layer 1, got %v", edge.from, edge.to, bends)
+			}
+		default:
+			if len(bends) != 0 {
+				t.Errorf("Expected %s -> %s to be straight, got %v", edge.from, edge.to, bends)
+			}
+		}
+	}
+}
+
+func TestLayoutReducesCrossings(t *testing.T) {
+	source := `nfa crossed {
+	initial s;
+	state a;
+	state b;
+	final x;
+	final y;
+
+	on s -> a when "1";
+	on s -> b when "2";
+	on a -> y when "3";
+	on b -> x when "4";
+}`
+	graph := automatonGraphs(getDefinitions(t, source))[0]
+	layout := layoutGraph(graph)
+
+	// a sits above b, so y must sit above x for the edges not to cross
+	above := layout.position["a"].Y < layout.position["b"].Y
+	if above != (layout.position["y"].Y < layout.position["x"].Y) {
+		t.Errorf("Expected no crossing, got positions %v", layout.position)
+	}
+}
+
+func TestRenderSVG(t *testing.T) {
+	var b bytes.Buffer
+	if err := RenderSVG(&b, getDefinitions(t, cyclicSource+"\n"+parallelSource)); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	svg := b.String()
+
+	// The output must be well-formed XML
+	decoder := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		if _, err := decoder.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Expected well-formed SVG, got: %v\n%s", err, svg)
+		}
+	}
+
+	for _, fragment := range []string{
+		`<g class="automaton" id="cycle">`,
+		`<g class="automaton" id="tags">`,
+		`&quot;&lt;a&gt;&quot; or <tspan font-style="italic">/[0-9]+/</tspan>`,
+		` C `, // The self-loop on q2
+		` Q `, // The curved q0 <-> q1 pair
+	} {
+		if !strings.Contains(svg, fragment) {
+			t.Errorf("Expected %q in output", fragment)
+		}
+	}
+	// One entry arrow per automaton, and two circles per final state
+	if n := strings.Count(svg, "<line "); n != 2 {
+		t.Errorf("Expected 2 entry arrows, got %d", n)
+	}
+	if n := strings.Count(svg, "<circle "); n != 8 {
+		t.Errorf("Expected 8 circles, got %d", n)
+	}
+}
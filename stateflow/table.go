@@ -0,0 +1,625 @@
+package stateflow
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A dfa can be compiled into a table indexed by state and input byte that
+// accepts exactly the inputs the interpreter accepts. The interpreter
+// matches every condition of the current state against the rest of the
+// input and takes the longest match, so the table must read ahead: while
+// some condition may still match a longer symbol, it also follows the
+// run that took the longest match seen so far, and falls back to it when
+// no condition gets further.
+//
+// Conditions are compiled at rune granularity. Runes fall into classes
+// that no condition tells apart, and regexes are simulated with the
+// ordered thread lists of Go's regexp package, so a regex matches what
+// regexp.FindStringIndex would. The rune steps are then spelled out byte
+// by byte, decoding invalid UTF-8 into utf8.RuneError as Go does.
+
+// maxTableStates bounds the states explored while compiling a table.
+// Conditions that overlap over long inputs, such as /a+b/ next to "a",
+// need a lookahead that grows with the input and hit this limit.
+const maxTableStates = 1 << 14
+
+// matchTable is a dfa over bytes. Bytes that no state tells apart share
+// a class, and each state has a dense row with a target per class, so
+// reading a byte is a single lookup. State 0 is the dead state, which
+// rejects every input.
+type matchTable struct {
+	start   int
+	accept  []bool
+	classes [256]int // Class of every byte, numbered in byte order
+	width   int      // Number of classes
+	next    []int    // next[state*width+class]
+}
+
+// match reports whether the table accepts the input
+func (t *matchTable) match(input string) bool {
+	state := t.start
+	for i := 0; i < len(input); i++ {
+		state = t.next[state*t.width+t.classes[input[i]]]
+		if state == 0 {
+			return false
+		}
+	}
+	return t.accept[state]
+}
+
+// row returns the targets of a state, by class
+func (t *matchTable) row(state int) []int {
+	return t.next[state*t.width : (state+1)*t.width]
+}
+
+type tableCondition struct {
+	target  int
+	literal []rune       // Runes of a string condition
+	prog    *syntax.Prog // Program of a regex condition
+}
+
+// scanConfig is where a run can be while reading a symbol: the state the
+// symbol started in, how far each condition of that state got, and the
+// configuration reached by taking the longest match so far, if any
+type scanConfig struct {
+	state    int
+	fresh    bool // No rune of the symbol was read yet
+	prev     rune // Previous rune, for assertions waiting on the next one
+	progress []scanProgress
+	fallback int // -1 when nothing matched yet
+}
+
+type scanProgress struct {
+	pos     int      // Runes of a string condition read so far, -1 when it failed
+	threads []uint32 // Instructions of a regex condition, by priority
+}
+
+type assertionContext struct {
+	prev, next rune // -1 at the start or end of the input
+	known      bool // Whether the next rune is known yet
+}
+
+type tableCompiler struct {
+	name       string
+	final      []bool
+	conditions [][]tableCondition // Outgoing conditions by state, in the order they are tried
+	classes    []rune             // First rune of every class, sorted
+	configs    []*scanConfig
+	ids        map[string]int
+	steps      map[[2]int]int
+}
+
+// compileTable builds the table of a dfa
+func compileTable(automaton *Automaton) (*matchTable, error) {
+	if automaton.Kind != DFA {
+		return nil, fmt.Errorf("'%s' is an nfa; only dfa automata can be compiled", automaton.Name)
+	}
+	c := &tableCompiler{
+		name:  automaton.Name,
+		ids:   make(map[string]int),
+		steps: make(map[[2]int]int),
+	}
+	index := make(map[string]int)
+	for i, state := range automaton.States {
+		index[state.name.lexeme] = i
+		c.final = append(c.final, automaton.IsFinal(state.name.lexeme))
+	}
+
+	bounds := map[rune]bool{0: true, utf8.RuneError: true, utf8.RuneError + 1: true}
+	addRange := func(lo, hi rune) {
+		bounds[lo] = true
+		if hi < unicode.MaxRune {
+			bounds[hi+1] = true
+		}
+	}
+	// Assertions tell newlines and word characters apart
+	for _, r := range []rune{'\n', '_'} {
+		addRange(r, r)
+	}
+	addRange('0', '9')
+	addRange('A', 'Z')
+	addRange('a', 'z')
+
+	for _, state := range automaton.States {
+		var conditions []tableCondition
+		for _, t := range automaton.transitions[state.name.lexeme] {
+			target, ok := index[t.decl.toState.lexeme]
+			if !ok {
+				return nil, fmt.Errorf("line %d: unknown state '%s'", t.decl.Line(), t.decl.toState.lexeme)
+			}
+			for i, matcher := range t.conditions {
+				condition := tableCondition{target: target}
+				if matcher.pattern == nil {
+					if !utf8.ValidString(matcher.text) || strings.ContainsRune(matcher.text, utf8.RuneError) {
+						return nil, fmt.Errorf("line %d: string %s is not valid UTF-8", t.decl.Line(), strconv.Quote(matcher.text))
+					}
+					condition.literal = []rune(matcher.text)
+					for _, r := range condition.literal {
+						addRange(r, r)
+					}
+				} else {
+					prog, err := compileCondition(t.decl.conditions[i].(RegexCondition).pattern)
+					if err != nil {
+						return nil, fmt.Errorf("line %d: %v", t.decl.Line(), err)
+					}
+					for _, inst := range prog.Inst {
+						for _, r := range instRanges(inst) {
+							addRange(r[0], r[1])
+						}
+					}
+					condition.prog = prog
+				}
+				conditions = append(conditions, condition)
+			}
+		}
+		c.conditions = append(c.conditions, conditions)
+	}
+	for r := range bounds {
+		c.classes = append(c.classes, r)
+	}
+	slices.Sort(c.classes)
+
+	start, ok := index[automaton.Initial]
+	if !ok {
+		return nil, fmt.Errorf("unknown initial state '%s'", automaton.Initial)
+	}
+	return c.build(c.fresh(start))
+}
+
+// Compiles a regex condition as the interpreter does
+func compileCondition(pattern string) (*syntax.Prog, error) {
+	re, err := syntax.Parse(`^(?:`+strings.Trim(pattern, "/")+`)`, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	return syntax.Compile(re.Simplify())
+}
+
+// Returns the rune ranges an instruction tells apart
+func instRanges(inst syntax.Inst) [][2]rune {
+	var ranges [][2]rune
+	switch inst.Op {
+	case syntax.InstRune:
+		if len(inst.Rune) == 1 {
+			r := inst.Rune[0]
+			ranges = append(ranges, [2]rune{r, r})
+			if syntax.Flags(inst.Arg)&syntax.FoldCase != 0 {
+				for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+					ranges = append(ranges, [2]rune{f, f})
+				}
+			}
+			break
+		}
+		for i := 0; i+1 < len(inst.Rune); i += 2 {
+			ranges = append(ranges, [2]rune{inst.Rune[i], inst.Rune[i+1]})
+		}
+	case syntax.InstRune1:
+		ranges = append(ranges, [2]rune{inst.Rune[0], inst.Rune[0]})
+	}
+	return ranges
+}
+
+// Reports whether a rune instruction consumes the rune, as regexp does
+func matchesRune(inst *syntax.Inst, r rune) bool {
+	switch inst.Op {
+	case syntax.InstRune:
+		return inst.MatchRune(r)
+	case syntax.InstRune1:
+		return r == inst.Rune[0]
+	case syntax.InstRuneAny:
+		return true
+	case syntax.InstRuneAnyNotNL:
+		return r != '\n'
+	}
+	return false
+}
+
+func (c *tableCompiler) classOf(r rune) int {
+	return sort.Search(len(c.classes), func(i int) bool { return c.classes[i] > r }) - 1
+}
+
+// Interns a configuration and returns its id
+func (c *tableCompiler) intern(config *scanConfig) int {
+	waiting := false
+	var b strings.Builder
+	for i, p := range config.progress {
+		fmt.Fprintf(&b, "|%d", p.pos)
+		for _, pc := range p.threads {
+			fmt.Fprintf(&b, ",%d", pc)
+			waiting = waiting || c.conditions[config.state][i].prog.Inst[pc].Op == syntax.InstEmptyWidth
+		}
+	}
+	if !waiting {
+		config.prev = 0
+	}
+	key := fmt.Sprintf("%d %t %d %d%s", config.state, config.fresh, config.prev, config.fallback, b.String())
+	if id, ok := c.ids[key]; ok {
+		return id
+	}
+	c.ids[key] = len(c.configs)
+	c.configs = append(c.configs, config)
+	return len(c.configs) - 1
+}
+
+// Returns the configuration of a symbol starting in the state
+func (c *tableCompiler) fresh(state int) int {
+	config := &scanConfig{state: state, fresh: true, prev: -1, fallback: -1}
+	for _, condition := range c.conditions[state] {
+		var p scanProgress
+		if condition.prog == nil {
+			if len(condition.literal) == 0 {
+				p.pos = -1
+			}
+		} else {
+			seen := make([]bool, len(condition.prog.Inst))
+			p.threads = addThread(condition.prog, nil, seen, uint32(condition.prog.Start), assertionContext{prev: -1})
+			p.threads = cutAtMatch(condition.prog, p.threads)
+		}
+		config.progress = append(config.progress, p)
+	}
+	return c.intern(config)
+}
+
+// Adds the thread at pc to the list the way regexp does, following empty
+// instructions. Assertions on the next rune wait in the list until it is
+// known.
+func addThread(prog *syntax.Prog, list []uint32, seen []bool, pc uint32, ctx assertionContext) []uint32 {
+	if seen[pc] {
+		return list
+	}
+	seen[pc] = true
+	inst := &prog.Inst[pc]
+	switch inst.Op {
+	case syntax.InstFail:
+	case syntax.InstAlt, syntax.InstAltMatch:
+		list = addThread(prog, list, seen, inst.Out, ctx)
+		list = addThread(prog, list, seen, inst.Arg, ctx)
+	case syntax.InstNop, syntax.InstCapture:
+		list = addThread(prog, list, seen, inst.Out, ctx)
+	case syntax.InstEmptyWidth:
+		op := syntax.EmptyOp(inst.Arg)
+		if !ctx.known && op&^(syntax.EmptyBeginText|syntax.EmptyBeginLine) != 0 {
+			return append(list, pc)
+		}
+		// Only the bits on the previous rune matter when the next is unknown
+		if op&^syntax.EmptyOpContext(ctx.prev, ctx.next) == 0 {
+			list = addThread(prog, list, seen, inst.Out, ctx)
+		}
+	default:
+		list = append(list, pc)
+	}
+	return list
+}
+
+// Drops the threads after the first match, which regexp never runs
+func cutAtMatch(prog *syntax.Prog, list []uint32) []uint32 {
+	for i, pc := range list {
+		if prog.Inst[pc].Op == syntax.InstMatch {
+			return list[:i+1]
+		}
+	}
+	return list
+}
+
+// Decides the assertions waiting for the next rune, or the end of the
+// input when next is -1
+func (c *tableCompiler) resolve(config *scanConfig, next rune) []scanProgress {
+	progress := slices.Clone(config.progress)
+	ctx := assertionContext{prev: config.prev, next: next, known: true}
+	for i, condition := range c.conditions[config.state] {
+		if condition.prog == nil {
+			continue
+		}
+		seen := make([]bool, len(condition.prog.Inst))
+		var threads []uint32
+		for _, pc := range progress[i].threads {
+			if condition.prog.Inst[pc].Op == syntax.InstEmptyWidth {
+				threads = addThread(condition.prog, threads, seen, pc, ctx)
+			} else if !seen[pc] {
+				seen[pc] = true
+				threads = append(threads, pc)
+			}
+		}
+		progress[i].threads = cutAtMatch(condition.prog, threads)
+	}
+	return progress
+}
+
+// Returns the first condition matching the runes read so far, or -1
+func (c *tableCompiler) matchIndex(state int, progress []scanProgress) int {
+	for i, condition := range c.conditions[state] {
+		p := progress[i]
+		if condition.prog == nil && p.pos == len(condition.literal) {
+			return i
+		}
+		if n := len(p.threads); n > 0 && condition.prog.Inst[p.threads[n-1]].Op == syntax.InstMatch {
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns the configuration after reading a rune of the class, or -1
+func (c *tableCompiler) step(id, class int) int {
+	key := [2]int{id, class}
+	if next, ok := c.steps[key]; ok {
+		return next
+	}
+	config := c.configs[id]
+	r := c.classes[class]
+	progress := c.resolve(config, r)
+
+	fallback := config.fallback
+	if !config.fresh {
+		if i := c.matchIndex(config.state, progress); i >= 0 {
+			fallback = c.fresh(c.conditions[config.state][i].target)
+		}
+	}
+	if fallback >= 0 {
+		fallback = c.step(fallback, class)
+	}
+
+	next := &scanConfig{state: config.state, prev: r, fallback: fallback}
+	alive := false
+	for i, condition := range c.conditions[config.state] {
+		p := scanProgress{pos: -1}
+		if condition.prog == nil {
+			if pos := progress[i].pos; pos >= 0 && pos < len(condition.literal) && condition.literal[pos] == r {
+				p.pos = pos + 1
+				alive = true
+			}
+		} else {
+			seen := make([]bool, len(condition.prog.Inst))
+			for _, pc := range progress[i].threads {
+				inst := &condition.prog.Inst[pc]
+				if inst.Op == syntax.InstMatch {
+					break
+				}
+				if matchesRune(inst, r) {
+					p.threads = addThread(condition.prog, p.threads, seen, inst.Out, assertionContext{prev: r})
+				}
+			}
+			p.threads = cutAtMatch(condition.prog, p.threads)
+			alive = alive || len(p.threads) > 0
+		}
+		next.progress = append(next.progress, p)
+	}
+
+	result := fallback
+	if alive {
+		result = c.intern(next)
+	}
+	c.steps[key] = result
+	return result
+}
+
+// Reports whether the input is accepted when it ends in the configuration
+func (c *tableCompiler) acceptsAtEnd(id int) bool {
+	config := c.configs[id]
+	if config.fresh {
+		return c.final[config.state]
+	}
+	if i := c.matchIndex(config.state, c.resolve(config, -1)); i >= 0 {
+		return c.final[c.conditions[config.state][i].target]
+	}
+	return config.fallback >= 0 && c.acceptsAtEnd(config.fallback)
+}
+
+// byteNode is a configuration in the middle of a multi-byte rune
+type byteNode struct {
+	config int
+	read   int // Bytes of the rune read so far, 0 between runes
+	left   int // Continuation bytes still expected
+	base   rune
+	lo, hi rune // Runes the bytes read can still complete to
+}
+
+// Returns a key telling apart nodes that behave differently
+func (c *tableCompiler) nodeKey(n byteNode) string {
+	if n.read == 0 {
+		return strconv.Itoa(n.config)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %d %d %d %d", n.config, n.read, n.left, n.lo-n.base, n.hi-n.base)
+	for class := c.classOf(n.lo); class < len(c.classes); class++ {
+		from := max(c.classes[class], n.lo)
+		if from > n.hi {
+			break
+		}
+		fmt.Fprintf(&b, " %d:%d", from-n.base, class)
+	}
+	return b.String()
+}
+
+// Reads a byte following Go's UTF-8 decoding, where every byte of an
+// invalid or truncated sequence is a utf8.RuneError of its own
+func (c *tableCompiler) advance(n byteNode, b byte) byteNode {
+	if n.read > 0 {
+		if b >= 0x80 && b <= 0xBF {
+			shift := 6 * (n.left - 1)
+			base := n.base + rune(b&0x3F)<<shift
+			lo, hi := max(base, n.lo), min(base+1<<shift-1, n.hi)
+			if lo <= hi {
+				if n.left == 1 {
+					return byteNode{config: c.step(n.config, c.classOf(lo))}
+				}
+				return byteNode{config: n.config, read: n.read + 1, left: n.left - 1, base: base, lo: lo, hi: hi}
+			}
+		}
+		config := c.flush(n)
+		if config < 0 {
+			return byteNode{config: -1}
+		}
+		n = byteNode{config: config}
+	}
+
+	switch {
+	case b < utf8.RuneSelf:
+		return byteNode{config: c.step(n.config, c.classOf(rune(b)))}
+	case b >= 0xC2 && b <= 0xDF:
+		base := rune(b&0x1F) << 6
+		return byteNode{config: n.config, read: 1, left: 1, base: base, lo: base, hi: base + 0x3F}
+	case b >= 0xE0 && b <= 0xEF:
+		base := rune(b&0x0F) << 12
+		hi := base + 0xFFF
+		if b == 0xED {
+			hi = 0xD7FF // Surrogates are not valid
+		}
+		return byteNode{config: n.config, read: 1, left: 2, base: base, lo: max(base, 0x800), hi: hi}
+	case b >= 0xF0 && b <= 0xF4:
+		base := rune(b&0x07) << 18
+		return byteNode{config: n.config, read: 1, left: 3, base: base, lo: max(base, 0x10000), hi: min(base+0x3FFFF, unicode.MaxRune)}
+	}
+	return byteNode{config: c.step(n.config, c.classOf(utf8.RuneError))}
+}
+
+// Reads the bytes of an unfinished rune as errors
+func (c *tableCompiler) flush(n byteNode) int {
+	config := n.config
+	for i := 0; i < n.read && config >= 0; i++ {
+		config = c.step(config, c.classOf(utf8.RuneError))
+	}
+	return config
+}
+
+// Explores the byte nodes reachable from the configuration and returns
+// the minimal table
+func (c *tableCompiler) build(start int) (*matchTable, error) {
+	nodes := []byteNode{{config: -1}}
+	ids := map[string]int{}
+	intern := func(n byteNode) int {
+		if n.config < 0 {
+			return 0
+		}
+		key := c.nodeKey(n)
+		if id, ok := ids[key]; ok {
+			return id
+		}
+		ids[key] = len(nodes)
+		nodes = append(nodes, n)
+		return len(nodes) - 1
+	}
+
+	next := [][256]int{{}}
+	intern(byteNode{config: start})
+	for id := 1; id < len(nodes); id++ {
+		if len(nodes) > maxTableStates || len(c.configs) > maxTableStates {
+			return nil, fmt.Errorf("'%s' needs more than %d table states; its conditions overlap over too long inputs",
+				c.name, maxTableStates)
+		}
+		var row [256]int
+		for b := range row {
+			row[b] = intern(c.advance(nodes[id], byte(b)))
+		}
+		next = append(next, row)
+	}
+
+	accept := make([]bool, len(nodes))
+	for id := 1; id < len(nodes); id++ {
+		if config := c.flush(nodes[id]); config >= 0 {
+			accept[id] = c.acceptsAtEnd(config)
+		}
+	}
+	return minimizeTable(next, accept, 1), nil
+}
+
+// minimizeTable merges equivalent states by partition refinement and
+// numbers the states in breadth-first order, the dead state first
+func minimizeTable(next [][256]int, accept []bool, start int) *matchTable {
+	block := make([]int, len(next))
+	for i := range next {
+		if accept[i] {
+			block[i] = 1
+		}
+	}
+	for blocks := 0; ; {
+		signatures := make(map[[257]int]int)
+		refined := make([]int, len(next))
+		for i, row := range next {
+			var signature [257]int
+			signature[256] = block[i]
+			for b, target := range row {
+				signature[b] = block[target]
+			}
+			id, ok := signatures[signature]
+			if !ok {
+				id = len(signatures)
+				signatures[signature] = id
+			}
+			refined[i] = id
+		}
+		block = refined
+		if len(signatures) == blocks {
+			break
+		}
+		blocks = len(signatures)
+	}
+
+	// Any representative of a block will do, they all behave the same
+	representative := make(map[int]int)
+	for i := len(next) - 1; i >= 0; i-- {
+		representative[block[i]] = i
+	}
+	number := map[int]int{block[0]: 0}
+	order := []int{block[0]}
+	if _, ok := number[block[start]]; !ok {
+		number[block[start]] = 1
+		order = append(order, block[start])
+	}
+	for i := 1; i < len(order); i++ {
+		for _, target := range next[representative[order[i]]] {
+			if _, ok := number[block[target]]; !ok {
+				number[block[target]] = len(order)
+				order = append(order, block[target])
+			}
+		}
+	}
+
+	rows := make([][256]int, len(order))
+	table := &matchTable{start: number[block[start]]}
+	for state, b := range order {
+		for i, target := range next[representative[b]] {
+			rows[state][i] = number[block[target]]
+		}
+		table.accept = append(table.accept, accept[representative[b]])
+	}
+	compressTable(table, rows)
+	return table
+}
+
+// compressTable groups the bytes whose columns are the same in every row
+// into classes, and stores the rows with one column per class
+func compressTable(table *matchTable, rows [][256]int) {
+	columns := make(map[string]int)
+	var representatives []int // First byte of every class
+	column := make([]byte, 0, 8*len(rows))
+	for b := range 256 {
+		column = column[:0]
+		for _, row := range rows {
+			column = strconv.AppendInt(column, int64(row[b]), 10)
+			column = append(column, ',')
+		}
+		class, ok := columns[string(column)]
+		if !ok {
+			class = len(representatives)
+			columns[string(column)] = class
+			representatives = append(representatives, b)
+		}
+		table.classes[b] = class
+	}
+
+	table.width = len(representatives)
+	table.next = make([]int, 0, len(rows)*table.width)
+	for _, row := range rows {
+		for _, b := range representatives {
+			table.next = append(table.next, row[b])
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package stateflow
+
+import "testing"
+
+// Compares the table of every automaton in the source with the
+// interpreter on all inputs up to the given length over the alphabet
+func checkTable(t *testing.T, source string, alphabet []string, length int) {
+	t.Helper()
+	interpreter := getInterpreter(t, source)
+	for _, name := range interpreter.Automata() {
+		automaton := interpreter.Automaton(name)
+		table, err := compileTable(automaton)
+		if err != nil {
+			t.Fatalf("Expected %s to compile, got: %v", name, err)
+		}
+		inputs := []string{""}
+		for i := 0; i < length; i++ {
+			for _, input := range inputs {
+				for _, symbol := range alphabet {
+					inputs = append(inputs, input+symbol)
+				}
+			}
+		}
+		for _, input := range inputs {
+			if expected := automaton.Run(input).Accepted; table.match(input) != expected {
+				t.Errorf("%s: expected accepted=%v for %q", name, expected, input)
+			}
+		}
+	}
+}
+
+func TestTableMatchesInterpreter(t *testing.T) {
+	checkTable(t, counterSource, []string{"i", "n", "c", "1", "r"}, 5)
+	checkTable(t, counterSource, []string{"inc", "12", "reset", "x"}, 4)
+}
+
+func TestTableLongestMatchFallback(t *testing.T) {
+	checkTable(t, `dfa words {
+		initial q0;
+		state q1;
+		final q2;
+		on q0 -> q1 when "a";
+		on q0 -> q2 when "abc" or /b+c/;
+		on q1 -> q2 when "bd" or "b";
+		on q1 -> q0 when /[a-c]d?/;
+	}`, []string{"a", "b", "c", "d"}, 6)
+}
+
+func TestTableRegexSemantics(t *testing.T) {
+	checkTable(t, `dfa first {
+		initial q0;
+		state q1;
+		final q2;
+		on q0 -> q1 when /a|ab/;
+		on q1 -> q2 when /b$/ or /c\b/ or /(?i)x/;
+		on q1 -> q1 when /c*?d/;
+		on q2 -> q2 when /\n^/;
+	}`, []string{"a", "b", "c", "d", "X", "\n", "_"}, 5)
+}
+
+func TestTableUTF8(t *testing.T) {
+	checkTable(t, `dfa letters {
+		initial q0;
+		final q1;
+		on q0 -> q1 when /[é-ü]+/ or "ñ" or /\x{FFFD}/;
+		on q1 -> q1 when /./;
+	}`, []string{"é", "ñ", "a", "\xc3", "\xa9", "\xed\xa0", "\xf0\x9f", "\x80", "\xff"}, 4)
+}
+
+func TestTableRejectsNFA(t *testing.T) {
+	interpreter := getInterpreter(t, parallelSource)
+	if _, err := compileTable(interpreter.Automaton("tags")); err == nil {
+		t.Error("Expected an error compiling an nfa")
+	}
+}
+
+func TestTableByteClasses(t *testing.T) {
+	interpreter := getInterpreter(t, counterSource)
+	table, err := compileTable(interpreter.Automaton("counter"))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	// Digits, the letters of "inc" and "reset", and every other byte
+	if table.width != 9 {
+		t.Errorf("Expected 9 byte classes, got %d", table.width)
+	}
+	if len(table.next) != len(table.accept)*table.width {
+		t.Errorf("Expected %d transitions, got %d", len(table.accept)*table.width, len(table.next))
+	}
+	for b := '1'; b <= '9'; b++ {
+		if table.classes[b] != table.classes['0'] {
+			t.Errorf("Expected %q to share the class of '0'", b)
+		}
+	}
+	if table.classes['i'] == table.classes['n'] || table.classes['a'] != table.classes[0xff] {
+		t.Error("Expected classes to follow the conditions")
+	}
+}
@@ -0,0 +1,94 @@
+package stateflow
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Spacing of the exported states, in centimeters. Columns widen with the
+// longest label so conditions do not run into the states.
+const (
+	tikzColumn    = 2.5
+	tikzRow       = 2.0
+	tikzCharWidth = 0.18
+)
+
+const tikzHeader = `% Needs \usepackage{tikz} and \usetikzlibrary{automata, arrows.meta}
+`
+
+// exportTikZ writes every automaton as a tikzpicture using the automata
+// library. States sit where the layered layout puts them, pairs of opposite
+// transitions bend away from each other, edges spanning several layers
+// pass through the slots the layout kept free for them, and regex
+// conditions are set in italics.
+func exportTikZ(w io.Writer, graphs []*automatonGraph) error {
+	var b strings.Builder
+	b.WriteString(tikzHeader)
+	for _, graph := range graphs {
+		layout := layoutGraph(graph)
+		longest := 0
+		for _, edge := range graph.edges {
+			longest = max(longest, len(edge.label()))
+		}
+		column := max(tikzColumn, tikzCharWidth*float64(longest)+1)
+		at := func(p point) string {
+			return fmt.Sprintf("(%.2fcm, %.2fcm)", p.X*column, (0-p.Y)*tikzRow)
+		}
+
+		fmt.Fprintf(&b, "\n%% %s %s\n", strings.ToLower(string(graph.kind)), graph.name)
+		b.WriteString("\\begin{tikzpicture}[->, >={Stealth[round]}, shorten >=1pt, auto, semithick, initial text={}]\n")
+		for _, state := range graph.states {
+			style := "state"
+			switch state.kind {
+			case INITIAL:
+				style += ", initial"
+			case FINAL:
+				style += ", accepting"
+			}
+			fmt.Fprintf(&b, "  \\node[%s] (%s) at %s {%s};\n", style, state.name, at(layout.position[state.name]),
+				latexEscaper.Replace(state.name))
+		}
+
+		for _, edge := range graph.edges {
+			label := tikzLabel(edge.conditions)
+			bends := layout.bends[edge]
+			switch {
+			case edge.from == edge.to:
+				fmt.Fprintf(&b, "  \\path (%s) edge [loop above] node {%s} ();\n", edge.from, label)
+			case len(bends) > 0:
+				var path []string
+				for _, bend := range bends {
+					path = append(path, at(bend))
+				}
+				fmt.Fprintf(&b, "  \\draw[rounded corners] (%s) -- %s node[above] {%s} -- (%s);\n", edge.from,
+					strings.Join(path, " -- "), label, edge.to)
+			case hasOpposite(graph, edge):
+				fmt.Fprintf(&b, "  \\path (%s) edge [bend left] node {%s} (%s);\n", edge.from, label, edge.to)
+			default:
+				fmt.Fprintf(&b, "  \\path (%s) edge node {%s} (%s);\n", edge.from, label, edge.to)
+			}
+		}
+		b.WriteString("\\end{tikzpicture}\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Sets strings in typewriter type and regexes in italic typewriter type
+func tikzLabel(conditions []Condition) string {
+	var parts []string
+	for _, condition := range conditions {
+		text := "\\texttt{" + latexEscaper.Replace(conditionText(condition)) + "}"
+		if _, ok := condition.(RegexCondition); ok {
+			text = "\\textit{" + text + "}"
+		}
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, " or ")
+}
+
+var latexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`, "{", `\{`, "}", `\}`, "#", `\#`, "$", `\$`, "%", `\%`,
+	"&", `\&`, "_", `\_`, "~", `\textasciitilde{}`, "^", `\textasciicircum{}`,
+)
@@ -39,6 +39,7 @@ type Token struct {
 	tokenType TokenType
 	lexeme    string
 	line      int
+	column    int
 }
 
 func (t Token) String() string {
@@ -0,0 +1,104 @@
+package stateflow
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// The JSON form of a trace is
+//
+//	{
+//	  "automaton": "counter",
+//	  "input": "incx",
+//	  "accepted": false,
+//	  "steps": [
+//	    {"offset": 0, "symbol": "inc", "from": "q0", "to": "q1",
+//	     "transition": "q0 -> q1 when \"inc\"", "line": 6}
+//	  ],
+//	  "rejection": {"reason": "stuck", "state": "q1", "offset": 3, "symbol": "x",
+//	                "message": "no transition for symbol \"x\" in state q1 (offset 3)"}
+//	}
+//
+// where "rejection" is omitted for accepted inputs and "symbol" is omitted
+// when the reason is "non-final".
+
+type jsonTrace struct {
+	Automaton string         `json:"automaton"`
+	Input     string         `json:"input"`
+	Accepted  bool           `json:"accepted"`
+	Steps     []jsonStep     `json:"steps"`
+	Rejection *jsonRejection `json:"rejection,omitempty"`
+}
+
+type jsonStep struct {
+	Offset     int    `json:"offset"`
+	Symbol     string `json:"symbol"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Transition string `json:"transition"`
+	Line       int    `json:"line"`
+}
+
+type jsonRejection struct {
+	Reason  RejectReason `json:"reason"`
+	State   string       `json:"state"`
+	Offset  int          `json:"offset"`
+	Symbol  string       `json:"symbol,omitempty"`
+	Message string       `json:"message"`
+}
+
+// MarshalTraces encodes the results with every step they took
+func MarshalTraces(results []Result) ([]byte, error) {
+	traces := []jsonTrace{}
+	for _, result := range results {
+		trace := jsonTrace{
+			Automaton: result.Automaton,
+			Input:     result.Input,
+			Accepted:  result.Accepted,
+			Steps:     []jsonStep{},
+		}
+		for _, step := range result.Steps {
+			trace.Steps = append(trace.Steps, jsonStep{
+				Offset:     step.Pos,
+				Symbol:     step.Symbol,
+				From:       step.From,
+				To:         step.To,
+				Transition: step.Transition.String(),
+				Line:       step.Transition.Line(),
+			})
+		}
+		if r := result.Rejection; r != nil {
+			trace.Rejection = &jsonRejection{
+				Reason:  r.Reason,
+				State:   r.State,
+				Offset:  r.Pos,
+				Symbol:  r.Symbol,
+				Message: r.String(),
+			}
+		}
+		traces = append(traces, trace)
+	}
+	return marshalIndent(traces)
+}
+
+// WriteTrace prints the steps of a result as a table followed by the
+// verdict and, for rejected inputs, the reason
+func WriteTrace(w io.Writer, result Result) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "#\tOFFSET\tFROM\tSYMBOL\tTO\tTRANSITION\tLINE")
+	for i, step := range result.Steps {
+		fmt.Fprintf(table, "%d\t%d\t%s\t%s\t%s\t%s\t%d\n", i+1, step.Pos, step.From,
+			strconv.Quote(step.Symbol), step.To, step.Transition, step.Transition.Line())
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	if result.Accepted {
+		_, err := fmt.Fprintln(w, "accepted")
+		return err
+	}
+	_, err := fmt.Fprintf(w, "rejected: %s\n", result.Rejection)
+	return err
+}
@@ -0,0 +1,266 @@
+package stateflow
+
+import (
+	"fmt"
+	"math/bits"
+	"slices"
+)
+
+// Program is a program compiled for batch execution. Each automaton
+// becomes a matcher over flat arrays, or over a byte table for a dfa that
+// fits one, and each function body becomes bytecode for a small VM. A
+// compiled program only reports whether inputs are accepted; the
+// interpreter remains the way to get steps and rejection reasons.
+type Program struct {
+	machines  []machine
+	names     map[string]int // Machine index by automaton name
+	functions map[string]*compiledFunction
+}
+
+// A machine decides whether an automaton accepts an input, agreeing with
+// Automaton.Run
+type machine interface {
+	match(input string) bool
+}
+
+// Opcodes of function bytecode, each followed by its operands
+const (
+	opRun  int32 = iota // machine, parameter: runs a machine over an argument
+	opFail              // error: fails with an error found while compiling
+)
+
+type compiledFunction struct {
+	name   Token
+	params int
+	code   []int32
+	errors []error
+}
+
+// Compile compiles parsed definitions
+func Compile(defs []Definition) (*Program, error) {
+	c := &compiler{program: &Program{names: make(map[string]int), functions: make(map[string]*compiledFunction)}}
+	for _, def := range defs {
+		if _, err := def.Accept(c); err != nil {
+			return nil, err
+		}
+	}
+	// Calls are resolved once every automaton is known, since a function
+	// may use automata defined after it
+	for _, def := range c.functions {
+		c.compileFunction(def)
+	}
+	return c.program, nil
+}
+
+// Match reports whether the automaton with the given name accepts the input
+func (p *Program) Match(automaton, input string) (bool, error) {
+	index, ok := p.names[automaton]
+	if !ok {
+		return false, fmt.Errorf("Undefined automaton '%s'.", automaton)
+	}
+	return p.machines[index].match(input), nil
+}
+
+// Call runs the bytecode of a function with the given arguments and
+// returns whether each automaton it runs accepts its input, in order
+func (p *Program) Call(name string, args []string) ([]bool, error) {
+	function, ok := p.functions[name]
+	if !ok {
+		return nil, fmt.Errorf("Undefined function '%s'.", name)
+	}
+	if len(args) != function.params {
+		return nil, RuntimeError{&function.name, "Function '" + name + "' expects " +
+			pluralize(function.params, "argument") + fmt.Sprintf(" but got %d.", len(args))}
+	}
+
+	var verdicts []bool
+	code := function.code
+	for pc := 0; pc < len(code); {
+		switch code[pc] {
+		case opRun:
+			verdicts = append(verdicts, p.machines[code[pc+1]].match(args[code[pc+2]]))
+			pc += 3
+		case opFail:
+			return nil, function.errors[code[pc+1]]
+		}
+	}
+	return verdicts, nil
+}
+
+type compiler struct {
+	program   *Program
+	functions []FunctionDef
+	function  *compiledFunction // Function being compiled
+	params    map[string]int
+}
+
+func (c *compiler) VisitAutomatonDefDefinition(definition AutomatonDef) (any, error) {
+	automaton, err := NewAutomaton(&definition)
+	if err != nil {
+		return nil, err
+	}
+	m := compileMachine(automaton)
+	if index, ok := c.program.names[automaton.Name]; ok {
+		c.program.machines[index] = m
+	} else {
+		c.program.names[automaton.Name] = len(c.program.machines)
+		c.program.machines = append(c.program.machines, m)
+	}
+	return m, nil
+}
+
+func (c *compiler) VisitFunctionDefDefinition(definition FunctionDef) (any, error) {
+	c.functions = append(c.functions, definition)
+	return nil, nil
+}
+
+func (c *compiler) compileFunction(def FunctionDef) {
+	c.function = &compiledFunction{name: def.name, params: len(def.params)}
+	c.params = make(map[string]int)
+	for index, param := range def.params {
+		c.params[param.lexeme] = index
+	}
+	for _, statement := range def.statements {
+		if _, err := statement.Accept(c); err != nil {
+			// The interpreter reports the first error before running
+			// anything, so nothing else is worth running
+			c.function.code = []int32{opFail, int32(len(c.function.errors))}
+			c.function.errors = append(c.function.errors, err)
+			break
+		}
+	}
+	c.program.functions[def.name.lexeme] = c.function
+}
+
+func (c *compiler) VisitCallStatement(statement Call) (any, error) {
+	index, ok := c.program.names[statement.target.lexeme]
+	if !ok {
+		return nil, RuntimeError{&statement.target, "Undefined automaton '" + statement.target.lexeme + "'."}
+	}
+	param, ok := c.params[statement.input.lexeme]
+	if !ok {
+		return nil, RuntimeError{&statement.input, "Undefined variable '" + statement.input.lexeme + "'."}
+	}
+	c.function.code = append(c.function.code, opRun, int32(index), int32(param))
+	return nil, nil
+}
+
+// Compiles a dfa into a byte table, or an automaton that has none into
+// flat arrays
+func compileMachine(automaton *Automaton) machine {
+	if automaton.Kind == DFA {
+		// Automata too large for a table fall back to flat arrays
+		if table, err := compileTable(automaton); err == nil {
+			return table
+		}
+	}
+	return newFlatAutomaton(automaton)
+}
+
+// flatAutomaton is an automaton with numbered states and the conditions
+// leaving each state stored contiguously, in source order
+type flatAutomaton struct {
+	dfa        bool
+	start      int
+	final      []bool
+	first      []int // Conditions of state s are conditions[first[s]:first[s+1]]
+	conditions []flatCondition
+}
+
+type flatCondition struct {
+	conditionMatcher
+	target int
+}
+
+func newFlatAutomaton(automaton *Automaton) *flatAutomaton {
+	index := make(map[string]int)
+	var names []string
+	number := func(name string) int {
+		i, ok := index[name]
+		if !ok {
+			i = len(names)
+			index[name] = i
+			names = append(names, name)
+		}
+		return i
+	}
+	for _, state := range automaton.States {
+		number(state.name.lexeme)
+	}
+	for _, transitions := range automaton.transitions {
+		for _, t := range transitions {
+			number(t.decl.toState.lexeme)
+		}
+	}
+
+	f := &flatAutomaton{dfa: automaton.Kind == DFA, start: number(automaton.Initial)}
+	for _, name := range names {
+		f.final = append(f.final, automaton.IsFinal(name))
+		f.first = append(f.first, len(f.conditions))
+		for _, t := range automaton.transitions[name] {
+			for _, condition := range t.conditions {
+				f.conditions = append(f.conditions, flatCondition{condition, index[t.decl.toState.lexeme]})
+			}
+		}
+	}
+	f.first = append(f.first, len(f.conditions))
+	return f
+}
+
+func (f *flatAutomaton) match(input string) bool {
+	if f.dfa {
+		state := f.start
+		for pos := 0; pos < len(input); {
+			best, target := -1, 0
+			for _, condition := range f.conditions[f.first[state]:f.first[state+1]] {
+				if length := condition.match(input[pos:]); length > best {
+					best, target = length, condition.target
+				}
+			}
+			if best < 0 {
+				return false
+			}
+			state, pos = target, pos+best
+		}
+		return f.final[state]
+	}
+
+	// The runs of an nfa are kept as sets of states by input offset. Every
+	// condition consumes input, so offsets are visited in increasing order.
+	words := (len(f.final) + 63) / 64
+	pending := map[int][]uint64{0: make([]uint64, words)}
+	pending[0][f.start/64] |= 1 << (f.start % 64)
+	offsets := []int{0}
+	for len(offsets) > 0 {
+		pos := offsets[0]
+		offsets = offsets[1:]
+		states := pending[pos]
+		delete(pending, pos)
+		for word, set := range states {
+			for ; set != 0; set &= set - 1 {
+				state := word*64 + bits.TrailingZeros64(set)
+				if pos == len(input) {
+					if f.final[state] {
+						return true
+					}
+					continue
+				}
+				for _, condition := range f.conditions[f.first[state]:f.first[state+1]] {
+					length := condition.match(input[pos:])
+					if length < 0 {
+						continue
+					}
+					next, ok := pending[pos+length]
+					if !ok {
+						next = make([]uint64, words)
+						pending[pos+length] = next
+						i, _ := slices.BinarySearch(offsets, pos+length)
+						offsets = slices.Insert(offsets, i, pos+length)
+					}
+					next[condition.target/64] |= 1 << (condition.target % 64)
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package stateflow
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const ambiguousSource = `nfa words {
+	initial q0;
+	state q1;
+	final q2;
+
+	on q0 -> q1 when /[a-z]+/;
+	on q0 -> q2 when "ab";
+	on q1 -> q2 when /b+/ or "c";
+	on q1 -> q0 when "-";
+	on q2 -> q2 when /-+/;
+}`
+
+func TestFlatAutomatonMatchesInterpreter(t *testing.T) {
+	for _, source := range []string{counterSource, parallelSource, ambiguousSource} {
+		for _, def := range getDefinitions(t, source) {
+			automatonDef, ok := def.(*AutomatonDef)
+			if !ok {
+				continue
+			}
+			automaton, err := NewAutomaton(automatonDef)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			flat := newFlatAutomaton(automaton)
+			inputs := append(sampleInputs(automaton), "abb", "abc", "ab-ab", "aab-xb", "x<a>", "xx12", "inc12reset", "inc1x", "a-ab", "ab--", "abb-")
+			for _, input := range inputs {
+				if got, want := flat.match(input), automaton.Run(input).Accepted; got != want {
+					t.Errorf("%s: expected %v for %q, got %v", automaton.Name, want, input, got)
+				}
+			}
+		}
+	}
+}
+
+func TestProgramCall(t *testing.T) {
+	defs := getDefinitions(t, counterSource)
+	program, err := Compile(defs)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	interpreter := getInterpreter(t, counterSource)
+	for _, args := range [][]string{{"incinc", "inc"}, {"inc7", "incincreset"}, {"", "x"}} {
+		results, err := interpreter.Call("main", args)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		var want []bool
+		for _, result := range results {
+			want = append(want, result.Accepted)
+		}
+		got, err := program.Call("main", args)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("Expected %v for %q, got %v", want, args, got)
+		}
+	}
+
+	if _, err := program.Call("main", []string{"inc"}); err == nil || !strings.Contains(err.Error(), "expects 2 arguments but got 1") {
+		t.Errorf("Expected an argument count error, got: %v", err)
+	}
+	if _, err := program.Call("other", nil); err == nil {
+		t.Error("Expected an error for an undefined function")
+	}
+	if accepted, err := program.Match("counter", "incinc"); err != nil || !accepted {
+		t.Errorf("Expected counter to accept, got %v, %v", accepted, err)
+	}
+}
+
+func TestProgramCallErrors(t *testing.T) {
+	// Undefined names are only reported when the function is called, like
+	// the interpreter does
+	program, err := Compile(getDefinitions(t, counterSource+`
+fn broken(input) {
+	counter <- input;
+	missing <- input;
+}`))
+	if err != nil {
+		t.Fatalf("Expected no error compiling, got: %v", err)
+	}
+	if _, err := program.Call("broken", []string{"inc"}); err == nil || !strings.Contains(err.Error(), "Undefined automaton 'missing'") {
+		t.Errorf("Expected an undefined automaton error, got: %v", err)
+	}
+}
+
+func TestBench(t *testing.T) {
+	results, err := Bench(getDefinitions(t, counterSource+"\n"+parallelSource), nil, time.Millisecond)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].Engine != "byte table" || results[1].Engine != "flat arrays" {
+		t.Errorf("Unexpected engines %q and %q", results[0].Engine, results[1].Engine)
+	}
+	for _, result := range results {
+		if result.Interpreted.Runs == 0 || result.Compiled.Runs == 0 {
+			t.Errorf("Expected %s to run, got %+v", result.Automaton, result)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jposo/stateflow/stateflow"
+)
+
+const streamUsage = "Usage: stateflow stream [-state <file>] <filename>[:automaton] [<input file>...]"
+
+// runStream matches a dfa against one input read from the given files in
+// order, or from stdin, without loading it into memory. With -state the
+// position is restored from the file when it exists and saved back, so
+// an input can be matched across several runs. The exit status is 1 when
+// the input is not accepted.
+func runStream(args []string) int {
+	flags := flag.NewFlagSet("stream", flag.ExitOnError)
+	stateFile := flags.String("state", "", "resume from this file when it exists and save the position to it")
+	positional := parseInterspersed(flags, args)
+	if len(positional) < 1 {
+		fmt.Fprintln(os.Stderr, streamUsage)
+		return 1
+	}
+
+	filename, name := splitTarget(positional[0])
+	defs, status := loadProgram(filename)
+	if defs == nil {
+		return status
+	}
+	interpreter := stateflow.NewInterpreter()
+	if err := interpreter.Define(defs); err != nil {
+		fmt.Fprint(os.Stderr, err.Error())
+		return 70 // Runtime Error
+	}
+	if name == "" {
+		if automata := interpreter.Automata(); len(automata) == 1 {
+			name = automata[0]
+		} else {
+			fmt.Fprintf(os.Stderr, "Name the automaton to stream with %s:<automaton>.\n", filename)
+			return 1
+		}
+	}
+	automaton := interpreter.Automaton(name)
+	if automaton == nil {
+		fmt.Fprintf(os.Stderr, "Unknown automaton '%s'.\n", name)
+		return 1
+	}
+	stream, err := stateflow.NewStream(automaton)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error compiling automaton: %v\n", err)
+		return 1
+	}
+
+	if *stateFile != "" {
+		data, err := os.ReadFile(*stateFile)
+		if err == nil {
+			err = stream.UnmarshalBinary(data)
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "Error restoring position: %v\n", err)
+			return 1
+		}
+	}
+
+	inputs := positional[1:]
+	if len(inputs) == 0 {
+		if _, err := stream.ReadFrom(os.Stdin); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+			return 1
+		}
+	}
+	for _, input := range inputs {
+		if stream.Rejected() {
+			break
+		}
+		if err := streamFile(stream, input); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+			return 1
+		}
+	}
+
+	if *stateFile != "" {
+		data, _ := stream.MarshalBinary()
+		if err := os.WriteFile(*stateFile, data, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving position: %v\n", err)
+			return 1
+		}
+	}
+
+	switch {
+	case stream.Accepted():
+		fmt.Printf("%s: accepted after %d bytes\n", name, stream.Offset())
+		return 0
+	case stream.Rejected():
+		fmt.Printf("%s: rejected at byte offset %d\n", name, stream.Offset()-1)
+	default:
+		fmt.Printf("%s: not accepted yet after %d bytes\n", name, stream.Offset())
+	}
+	return 1
+}
+
+func streamFile(stream *stateflow.Stream, filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(stream, file)
+	return err
+}